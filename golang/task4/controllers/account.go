@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"fmt"
+	"go-blog/helpers"
+	"go-blog/models"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 邮件链接有效期
+const (
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+// 校验邮箱格式，不接受带显示名的地址
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// 发送邮箱验证邮件，令牌与当前邮箱绑定，更换邮箱后旧链接失效
+func sendVerifyEmail(user *models.User) {
+	token := helpers.SignUserToken(helpers.TokenVerifyEmail, user.ID, user.Email, time.Now().Add(verifyEmailTTL))
+	link := absoluteURL("/verify_email?token=" + url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening the link below within 24 hours:\n\n%s\n\nIf you did not sign up, please ignore this email.\n", user.Username, link)
+	helpers.SendMailAsync([]string{user.Email}, "Verify your email", body)
+}
+
+// 发送重置密码邮件，令牌与当前密码哈希绑定，密码修改后链接失效
+func sendResetPassword(user *models.User) {
+	token := helpers.SignUserToken(helpers.TokenResetPassword, user.ID, user.Password, time.Now().Add(resetPasswordTTL))
+	link := absoluteURL("/reset_password?token=" + url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nOpen the link below within 1 hour to reset your password:\n\n%s\n\nIf you did not request a password reset, please ignore this email.\n", user.Username, link)
+	helpers.SendMailAsync([]string{user.Email}, "Reset your password", body)
+}
+
+// 读取令牌对应的用户并校验签名
+func userFromToken(purpose, token string, state func(*models.User) string) (*models.User, error) {
+	userID, err := helpers.UserIDFromToken(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := models.GetUser(userID)
+	if err != nil {
+		return nil, helpers.ErrSignedTokenInvalid
+	}
+	if err = helpers.VerifyUserToken(purpose, token, state(user), time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GET /verify_email?token=
+func VerifyEmailGet(c *gin.Context) {
+	user, err := userFromToken(helpers.TokenVerifyEmail, c.Query("token"), func(user *models.User) string {
+		return user.Email
+	})
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	if !user.IsEmailVerified() {
+		if err = user.VerifyEmail(); err != nil {
+			HandleMessage(c, err.Error())
+			return
+		}
+		seelog.Infof("User[ID:%v] verified email %s", user.ID, user.Email)
+	}
+	c.HTML(http.StatusOK, "errors/error.html", gin.H{
+		"message": "Your email " + user.Email + " has been verified",
+	})
+}
+
+// POST /verify_email/resend 重新发送验证邮件
+func VerifyEmailResend(c *gin.Context) {
+	res := gin.H{}
+	defer writeJSON(c, res)
+	user := currentUser(c)
+	if user == nil {
+		res["message"] = "please login first"
+		return
+	}
+	if user.IsEmailVerified() {
+		res["message"] = "email already verified"
+		return
+	}
+	sendVerifyEmail(user)
+	res["succeed"] = true
+}
+
+func ForgotPasswordGet(c *gin.Context) {
+	c.HTML(http.StatusOK, "auth/forgot.html", gin.H{
+		"csrf": CSRFToken(c),
+	})
+}
+
+// POST /forgot_password 无论邮箱是否存在都返回相同提示，避免泄露注册信息
+func ForgotPasswordPost(c *gin.Context) {
+	email := strings.TrimSpace(c.PostForm("email"))
+	if !validEmail(email) {
+		c.HTML(http.StatusOK, "auth/forgot.html", gin.H{
+			"csrf":    CSRFToken(c),
+			"message": "email invalid",
+		})
+		return
+	}
+	if user, err := models.GetUserByEmail(email); err == nil && !user.Locked {
+		sendResetPassword(user)
+		seelog.Infof("User[ID:%v] requested password reset from %s", user.ID, c.ClientIP())
+	}
+	c.HTML(http.StatusOK, "auth/forgot.html", gin.H{
+		"csrf": CSRFToken(c),
+		"sent": true,
+	})
+}
+
+func resetPasswordState(user *models.User) string {
+	return user.Password
+}
+
+// GET /reset_password?token=
+func ResetPasswordGet(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := userFromToken(helpers.TokenResetPassword, token, resetPasswordState); err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	c.HTML(http.StatusOK, "auth/reset.html", gin.H{
+		"csrf":  CSRFToken(c),
+		"token": token,
+	})
+}
+
+// POST /reset_password 重置密码后吊销全部令牌，需重新登录
+func ResetPasswordPost(c *gin.Context) {
+	token := c.PostForm("token")
+	user, err := userFromToken(helpers.TokenResetPassword, token, resetPasswordState)
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	password := c.PostForm("password")
+	if err = helpers.ValidatePasswordStrength(password); err != nil {
+		c.HTML(http.StatusOK, "auth/reset.html", gin.H{
+			"csrf":    CSRFToken(c),
+			"token":   token,
+			"message": err.Error(),
+		})
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		HandleMessage(c, "Failed to hash password")
+		return
+	}
+	if err = user.UpdatePassword(string(hashedPassword)); err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	_ = models.ResetLoginFailure(user.Username)
+	// 能收到重置邮件即可证明邮箱属于该用户
+	if !user.IsEmailVerified() {
+		_ = user.VerifyEmail()
+	}
+	seelog.Infof("User[ID:%v] reset password", user.ID)
+	c.HTML(http.StatusOK, "auth/signin.html", gin.H{
+		"csrf":    CSRFToken(c),
+		"message": "Password has been reset, please sign in",
+	})
+}
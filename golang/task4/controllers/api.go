@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"go-blog/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API 统一错误码，与 HTTP 状态码保持一致
+const (
+	CodeSuccess      = http.StatusOK
+	CodeCreated      = http.StatusCreated
+	CodeBadRequest   = http.StatusBadRequest
+	CodeUnauthorized = http.StatusUnauthorized
+	CodeForbidden    = http.StatusForbidden
+	CodeNotFound     = http.StatusNotFound
+	CodeConflict     = http.StatusConflict
+	CodeServerError  = http.StatusInternalServerError
+)
+
+// 单条数据响应
+func apiData[T any](c *gin.Context, code int, payload T) {
+	c.JSON(code, models.DataResponse[T]{
+		BaseResponse: models.BaseResponse{Code: code, Msg: "success"},
+		Payload:      payload,
+	})
+}
+
+// 分页数据响应
+func apiPage[T any](c *gin.Context, payload []T, total int64, pageIndex, pageSize int) {
+	if payload == nil {
+		payload = []T{}
+	}
+	c.JSON(http.StatusOK, models.PageResponse[T]{
+		BaseResponse: models.BaseResponse{Code: CodeSuccess, Msg: "success"},
+		Payload:      payload,
+		Total:        total,
+		Current:      int64(pageIndex),
+		Size:         int64(pageSize),
+	})
+}
+
+// 错误响应，code 同时作为 HTTP 状态码
+func apiError(c *gin.Context, code int, msg string) {
+	c.AbortWithStatusJSON(code, models.BaseResponse{Code: code, Msg: msg})
+}
+
+// 读取分页参数 page、size，size 上限为 100
+func apiPageParams(c *gin.Context) (pageIndex, pageSize int) {
+	pageIndex = queryPageIndex(c)
+	pageSize = configPageSize()
+	if size, _ := strconv.Atoi(c.Query("size")); size > 0 && size <= 100 {
+		pageSize = size
+	}
+	return
+}
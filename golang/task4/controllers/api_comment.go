@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"go-blog/models"
+	"strings"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 新建、更新评论请求体
+type CommentRequest struct {
+	Content  string `json:"content" binding:"required"`
+	ParentID uint   `json:"parent_id"` // 回复的上级评论，仅新建时有效
+}
+
+// GET /api/v1/posts/:id/comments 返回嵌套的评论树
+func APICommentList(c *gin.Context) {
+	post, ok := apiLoadPost(c)
+	if !ok {
+		return
+	}
+	comments, err := models.ListCommentTreeByPostID(post.ID)
+	if err != nil {
+		seelog.Errorf("models.ListCommentTreeByPostID err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]models.CommentData, 0, len(comments))
+	for _, comment := range comments {
+		payload = append(payload, models.NewCommentData(comment))
+	}
+	apiData(c, CodeSuccess, payload)
+}
+
+// GET /api/v1/comments/:id
+func APICommentGet(c *gin.Context) {
+	comment, ok := apiLoadComment(c)
+	if !ok {
+		return
+	}
+	if !comment.IsApproved() {
+		apiError(c, CodeNotFound, "comment not found")
+		return
+	}
+	apiData(c, CodeSuccess, models.NewCommentData(comment))
+}
+
+// POST /api/v1/posts/:id/comments
+func APICommentCreate(c *gin.Context) {
+	var req CommentRequest
+	post, ok := apiLoadPost(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(strings.TrimSpace(req.Content)) == 0 {
+		apiError(c, CodeBadRequest, "content cannot be empty.")
+		return
+	}
+	user := currentUser(c)
+	comment := &models.Comment{
+		PostID:  post.ID,
+		Content: req.Content,
+		UserID:  user.ID,
+	}
+	if req.ParentID > 0 {
+		parent, err := models.GetCommentById(req.ParentID)
+		if err != nil || !parent.IsApproved() {
+			apiError(c, CodeBadRequest, "parent comment not found")
+			return
+		}
+		if err = comment.SetParent(parent); err != nil {
+			apiError(c, CodeBadRequest, err.Error())
+			return
+		}
+	}
+	comment.Moderate(post)
+	if err := comment.Insert(); err != nil {
+		seelog.Errorf("comment insert err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	notifyPendingComment(post, comment, user)
+	comment.User = *user
+	apiData(c, CodeCreated, models.NewCommentData(comment))
+}
+
+// PUT /api/v1/comments/:id
+func APICommentUpdate(c *gin.Context) {
+	var req CommentRequest
+	comment, ok := apiLoadOwnComment(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(strings.TrimSpace(req.Content)) == 0 {
+		apiError(c, CodeBadRequest, "content cannot be empty.")
+		return
+	}
+	post, err := models.GetPostById(comment.PostID)
+	if err != nil {
+		apiError(c, CodeNotFound, "post not found")
+		return
+	}
+	// 编辑后按文章的审核规则重新审核
+	comment.Content = req.Content
+	comment.Moderate(post)
+	if err = comment.UpdateContent(); err != nil {
+		seelog.Errorf("comment update err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	notifyPendingComment(post, comment, currentUser(c))
+	apiData(c, CodeSuccess, models.NewCommentData(comment))
+}
+
+// DELETE /api/v1/comments/:id
+func APICommentDelete(c *gin.Context) {
+	comment, ok := apiLoadOwnComment(c)
+	if !ok {
+		return
+	}
+	if err := comment.Delete(); err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	apiData(c, CodeSuccess, gin.H{"id": comment.ID})
+}
+
+func apiLoadComment(c *gin.Context) (*models.Comment, bool) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return nil, false
+	}
+	comment, err := models.GetCommentById(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "comment not found")
+		return nil, false
+	}
+	return comment, true
+}
+
+// 只有评论的作者才能修改、删除自己的评论
+func apiLoadOwnComment(c *gin.Context) (*models.Comment, bool) {
+	comment, ok := apiLoadComment(c)
+	if !ok {
+		return nil, false
+	}
+	if comment.UserID != currentUser(c).ID {
+		apiError(c, CodeForbidden, "only the author can modify this comment")
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"go-blog/jobs"
+	"go-blog/models"
+	"strings"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 新建、更新文章请求体
+type PostRequest struct {
+	Title       string     `json:"title" binding:"required"`
+	Content     string     `json:"content" binding:"required"`
+	Tags        []string   `json:"tags"`
+	Categories  []string   `json:"categories"`
+	Status      string     `json:"status"`       // draft/published/scheduled，默认 published
+	PublishedAt *time.Time `json:"published_at"` // 定时发布时间
+	// 评论审核规则：manual/trusted/auto，为空时新建默认 trusted，更新保持不变
+	CommentApproval string `json:"comment_approval"`
+}
+
+// GET /api/v1/posts?page=1&size=10&user_id=&tag=&category=&keyword=
+func APIPostList(c *gin.Context) {
+	var query models.PostQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	pageIndex, pageSize := apiPageParams(c)
+	posts, err := models.ListPostByQuery(query, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListPostByQuery err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	total, err := models.CountPostByQuery(query)
+	if err != nil {
+		seelog.Errorf("models.CountPostByQuery err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]models.PostData, 0, len(posts))
+	for _, post := range posts {
+		payload = append(payload, models.NewPostData(post))
+	}
+	apiPage(c, payload, total, pageIndex, pageSize)
+}
+
+// GET /api/v1/posts/:id
+func APIPostGet(c *gin.Context) {
+	post, ok := apiLoadPost(c)
+	if !ok {
+		return
+	}
+	apiData(c, CodeSuccess, models.NewPostData(post))
+}
+
+// POST /api/v1/posts
+func APIPostCreate(c *gin.Context) {
+	var req PostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	user := currentUser(c)
+	post := &models.Post{
+		Title:   strings.TrimSpace(req.Title),
+		Content: req.Content,
+		UserID:  user.ID,
+	}
+	if err := post.SetStatus(req.Status, req.PublishedAt); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	if !apiBindCommentApproval(c, post, req) {
+		return
+	}
+	if err := apiBindTaxonomy(post, req); err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	err := post.Insert()
+	if err == nil {
+		err = post.SaveRevision(user.ID)
+	}
+	if err != nil {
+		seelog.Errorf("post insert err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	if post.Status == models.PostStatusScheduled {
+		jobs.WakePublisher()
+	}
+	post.User = *user
+	apiData(c, CodeCreated, models.NewPostData(post))
+}
+
+// PUT /api/v1/posts/:id
+func APIPostUpdate(c *gin.Context) {
+	var req PostRequest
+	post, ok := apiLoadOwnPost(c)
+	if !ok {
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	if err := post.EnsureRevision(); err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	post.Title = strings.TrimSpace(req.Title)
+	post.Content = req.Content
+	if err := post.SetStatus(req.Status, req.PublishedAt); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	if !apiBindCommentApproval(c, post, req) {
+		return
+	}
+	err := post.Update()
+	if err == nil {
+		err = post.SaveRevision(currentUser(c).ID)
+	}
+	if err == nil {
+		err = post.UpdateStatus()
+	}
+	if err == nil {
+		err = post.UpdateCommentApproval()
+	}
+	if err == nil {
+		err = apiBindTaxonomy(post, req)
+	}
+	if err == nil {
+		err = post.ReplaceTags(post.Tags)
+	}
+	if err == nil {
+		err = post.ReplaceCategories(post.Categories)
+	}
+	if err != nil {
+		seelog.Errorf("post update err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	if post.Status == models.PostStatusScheduled {
+		jobs.WakePublisher()
+	}
+	post, _ = models.GetAnyPostById(post.ID)
+	apiData(c, CodeSuccess, models.NewPostData(post))
+}
+
+// DELETE /api/v1/posts/:id
+func APIPostDelete(c *gin.Context) {
+	post, ok := apiLoadOwnPost(c)
+	if !ok {
+		return
+	}
+	if err := post.LogicDelete(); err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	apiData(c, CodeSuccess, gin.H{"id": post.ID})
+}
+
+func apiBindTaxonomy(post *models.Post, req PostRequest) (err error) {
+	post.Tags, err = models.GetOrCreateTags(models.SplitNames(strings.Join(req.Tags, ",")))
+	if err != nil {
+		return
+	}
+	post.Categories, err = models.GetOrCreateCategories(models.SplitNames(strings.Join(req.Categories, ",")))
+	return
+}
+
+func apiBindCommentApproval(c *gin.Context, post *models.Post, req PostRequest) bool {
+	if len(req.CommentApproval) == 0 {
+		if len(post.CommentApproval) == 0 {
+			post.CommentApproval = models.CommentApprovalTrusted
+		}
+		return true
+	}
+	if !models.IsCommentApproval(req.CommentApproval) {
+		apiError(c, CodeBadRequest, models.ErrCommentApprovalInvalid.Error())
+		return false
+	}
+	post.CommentApproval = req.CommentApproval
+	return true
+}
+
+func apiLoadPost(c *gin.Context) (*models.Post, bool) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return nil, false
+	}
+	post, err := models.GetPostById(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "post not found")
+		return nil, false
+	}
+	return post, true
+}
+
+// 只有文章的作者或编辑、管理员才能修改、删除文章，包含草稿与定时发布的文章
+func apiLoadOwnPost(c *gin.Context) (*models.Post, bool) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return nil, false
+	}
+	post, err := models.GetAnyPostById(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "post not found")
+		return nil, false
+	}
+	if !currentUser(c).CanEditPost(post) {
+		apiError(c, CodeForbidden, "only the author can modify this post")
+		return nil, false
+	}
+	return post, true
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"go-blog/models"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/v1/users?page=1&size=10&username=&email= 需要用户管理权限，避免按邮箱探测注册用户
+func APIUserList(c *gin.Context) {
+	var query models.UserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	pageIndex, pageSize := apiPageParams(c)
+	users, err := models.ListUser(query, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListUser err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	total, err := models.CountUser(query)
+	if err != nil {
+		seelog.Errorf("models.CountUser err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]models.UserData, 0, len(users))
+	for _, user := range users {
+		payload = append(payload, models.NewUserData(user))
+	}
+	apiPage(c, payload, total, pageIndex, pageSize)
+}
+
+// GET /api/v1/users/me
+func APIUserMe(c *gin.Context) {
+	apiData(c, CodeSuccess, models.NewUserData(currentUser(c)))
+}
+
+// GET /api/v1/users/:id
+func APIUserGet(c *gin.Context) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return
+	}
+	user, err := models.GetUser(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "user not found")
+		return
+	}
+	apiData(c, CodeSuccess, models.NewUserData(user))
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"fmt"
+	"go-blog/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 读取路由中的年份与月份，month 为空时只读取年份
+func archiveParams(c *gin.Context) (year, month int, ok bool) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil || year < 1 || year > 9999 {
+		return 0, 0, false
+	}
+	if param := c.Param("month"); len(param) > 0 {
+		month, err = strconv.Atoi(param)
+		if err != nil || month < 1 || month > 12 {
+			return 0, 0, false
+		}
+	}
+	return year, month, true
+}
+
+// 某月的每一天及其文章数
+func archiveCalendar(year, month int) ([]*models.ArchiveDay, error) {
+	counts, err := models.ListArchiveDays(year, month)
+	if err != nil {
+		return nil, err
+	}
+	first := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	days := make([]*models.ArchiveDay, first.AddDate(0, 1, -1).Day())
+	for i := range days {
+		days[i] = &models.ArchiveDay{Date: first.AddDate(0, 0, i).Format("2006-01-02"), Day: i + 1}
+	}
+	for _, count := range counts {
+		if count.Day >= 1 && count.Day <= len(days) {
+			days[count.Day-1].Total = count.Total
+		}
+	}
+	return days, nil
+}
+
+// 按周排列的日历，每周从周一开始，月初与月末不足一周的位置为 nil
+func calendarWeeks(year, month int, days []*models.ArchiveDay) [][]*models.ArchiveDay {
+	offset := (int(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).Weekday()) + 6) % 7
+	cells := make([]*models.ArchiveDay, offset, offset+len(days)+6)
+	cells = append(cells, days...)
+	for len(cells)%7 != 0 {
+		cells = append(cells, nil)
+	}
+	weeks := make([][]*models.ArchiveDay, 0, len(cells)/7)
+	for i := 0; i < len(cells); i += 7 {
+		weeks = append(weeks, cells[i:i+7])
+	}
+	return weeks
+}
+
+func renderArchive(c *gin.Context, year, month int, data gin.H) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	posts, err := models.ListPostByArchive(year, month, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListPostByArchive err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	total, err := models.CountPostByArchive(year, month)
+	if err != nil {
+		seelog.Errorf("models.CountPostByArchive err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	years, _ := models.ListArchiveYears()
+	data["user"] = currentUser(c)
+	data["archiveYears"] = years
+	for _, summary := range years {
+		if summary.Year == year {
+			data["archiveYear"] = summary
+		}
+	}
+	renderPostList(c, posts, total, pageIndex, pageSize, data)
+}
+
+// 按年归档，附带全年各月的文章数
+func ArchiveYearGet(c *gin.Context) {
+	year, _, ok := archiveParams(c)
+	if !ok {
+		Handle404(c)
+		return
+	}
+	renderArchive(c, year, 0, gin.H{
+		"title": fmt.Sprintf("归档：%d年", year),
+	})
+}
+
+// 按月归档，附带当月的日历
+func ArchiveMonthGet(c *gin.Context) {
+	year, month, ok := archiveParams(c)
+	if !ok {
+		Handle404(c)
+		return
+	}
+	days, err := archiveCalendar(year, month)
+	if err != nil {
+		seelog.Errorf("models.ListArchiveDays err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	renderArchive(c, year, month, gin.H{
+		"title":    fmt.Sprintf("归档：%d年%02d月", year, month),
+		"calendar": calendarWeeks(year, month, days),
+	})
+}
+
+// GET /api/v1/archives 按年汇总的归档，包含每月的文章数
+func APIArchiveList(c *gin.Context) {
+	years, err := models.ListArchiveYears()
+	if err != nil {
+		seelog.Errorf("models.ListArchiveYears err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]gin.H, 0, len(years))
+	for _, year := range years {
+		months := make([]gin.H, 0, len(year.Months))
+		for _, month := range year.Months {
+			months = append(months, gin.H{"month": month.Month, "total": month.Total})
+		}
+		payload = append(payload, gin.H{"year": year.Year, "total": year.Total, "months": months})
+	}
+	apiData(c, CodeSuccess, payload)
+}
+
+// GET /api/v1/archives/:year/:month/calendar 某月每天发布的文章数
+func APIArchiveCalendar(c *gin.Context) {
+	year, month, ok := archiveParams(c)
+	if !ok || month == 0 {
+		apiError(c, CodeBadRequest, "year or month invalid")
+		return
+	}
+	days, err := archiveCalendar(year, month)
+	if err != nil {
+		seelog.Errorf("models.ListArchiveDays err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	total := 0
+	for _, day := range days {
+		total += day.Total
+	}
+	apiData(c, CodeSuccess, gin.H{
+		"year":  year,
+		"month": month,
+		"total": total,
+		"days":  days,
+	})
+}
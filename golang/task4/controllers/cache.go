@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"go-blog/helpers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 侧边栏数据在缓存中的键
+const sidebarCacheKey = "sidebar"
+
+var (
+	cacheOnce sync.Once
+	pageCache *helpers.LRU // 匿名访问的公开页面
+	dataCache *helpers.LRU // 侧边栏等查询结果
+	// 每次内容变更加一，渲染期间发生变更的页面不写入缓存
+	cacheGeneration atomic.Int64
+)
+
+// 缓存的页面
+type cachedPage struct {
+	status       int
+	contentType  string
+	body         []byte
+	etag         string
+	lastModified time.Time
+}
+
+func initCache() {
+	cacheOnce.Do(func() {
+		size := 256
+		if cfg := system.GetConfiguration(); cfg != nil && cfg.Cache.Pages > 0 {
+			size = cfg.Cache.Pages
+		}
+		pageCache = helpers.NewLRU(size)
+		dataCache = helpers.NewLRU(16)
+		// 文章、评论等变更后清空缓存
+		models.OnContentChange(func(string) {
+			InvalidateCache()
+		})
+	})
+}
+
+func cacheEnabled() bool {
+	cfg := system.GetConfiguration()
+	return cfg != nil && cfg.Cache.Enabled
+}
+
+// InvalidateCache 清空页面与侧边栏缓存
+func InvalidateCache() {
+	initCache()
+	cacheGeneration.Add(1)
+	pageCache.Purge()
+	dataCache.Purge()
+}
+
+// 侧边栏数据，缓存至内容变更或阅读数写入
+func cachedSidebarData() gin.H {
+	if !cacheEnabled() {
+		return sidebarData()
+	}
+	initCache()
+	if data, ok := dataCache.Get(sidebarCacheKey); ok {
+		return copyH(data.(gin.H))
+	}
+	generation := cacheGeneration.Load()
+	data := sidebarData()
+	if generation == cacheGeneration.Load() {
+		dataCache.Set(sidebarCacheKey, data)
+	}
+	return copyH(data)
+}
+
+func copyH(data gin.H) gin.H {
+	h := make(gin.H, len(data))
+	for key, value := range data {
+		h[key] = value
+	}
+	return h
+}
+
+// 暂存响应，渲染完成后再计算 ETag 并输出
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return false
+}
+
+// 未登录的 GET 请求可以共用缓存的页面
+func pageCacheable(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet || len(c.GetHeader("Authorization")) > 0 {
+		return false
+	}
+	if currentUser(c) != nil {
+		return false
+	}
+	return sessions.Default(c).Get(SessionKey) == nil
+}
+
+// CachePage 为公开页面增加 ETag/Last-Modified 与条件请求；未登录访问的页面缓存在内存中，内容变更后失效
+func CachePage(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cacheable := cacheEnabled() && pageCacheable(c)
+		key := c.Request.URL.RequestURI()
+		if cacheable {
+			initCache()
+			if page, ok := pageCache.Get(key); ok {
+				servePage(c, page.(*cachedPage), true)
+				return
+			}
+		}
+
+		generation := cacheGeneration.Load()
+		lastModified := models.LastContentChange()
+		writer := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: writer, status: http.StatusOK}
+		c.Writer = buffered
+		handler(c)
+		c.Writer = writer
+
+		page := &cachedPage{
+			status:      buffered.status,
+			contentType: writer.Header().Get("Content-Type"),
+			body:        buffered.body.Bytes(),
+		}
+		page.etag = pageETag(page.body)
+		if cacheable {
+			page.lastModified = lastModified
+			if page.status == http.StatusOK && generation == cacheGeneration.Load() {
+				pageCache.Set(key, page)
+			}
+		}
+		servePage(c, page, cacheable)
+	}
+}
+
+func pageETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func servePage(c *gin.Context, page *cachedPage, public bool) {
+	if page.status != http.StatusOK {
+		c.Data(page.status, page.contentType, page.body)
+		return
+	}
+	if public {
+		// 登录状态不同，页面内容不同
+		c.Header("Cache-Control", "no-cache")
+		c.Writer.Header().Add("Vary", "Cookie")
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	if checkNotModified(c, page.lastModified, page.etag) {
+		return
+	}
+	c.Data(page.status, page.contentType, page.body)
+}
+
+// 缓存命中统计
+func CacheStats(c *gin.Context) {
+	initCache()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": cacheEnabled(),
+		"pages":   pageCache.Stats(),
+		"sidebar": dataCache.Stats(),
+	})
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"fmt"
+	"go-blog/helpers"
 	"go-blog/models"
 
 	"github.com/cihub/seelog"
@@ -44,7 +46,7 @@ func CommentPost(c *gin.Context) {
 		res["message"] = err.Error()
 		return
 	}
-	_, err = models.GetPostById(pid)
+	post, err := models.GetPostById(pid)
 	if err != nil {
 		res["message"] = err.Error()
 		return
@@ -54,6 +56,25 @@ func CommentPost(c *gin.Context) {
 		Content: content,
 		UserID:  user.ID,
 	}
+	// 回复评论：上级评论必须属于同一篇文章
+	if len(c.PostForm("parentId")) > 0 {
+		parentID, err := PostFormUint(c, "parentId")
+		if err != nil {
+			res["message"] = err.Error()
+			return
+		}
+		parent, err := models.GetCommentById(parentID)
+		if err != nil || !parent.IsApproved() {
+			res["message"] = "parent comment not found"
+			return
+		}
+		if err = comment.SetParent(parent); err != nil {
+			res["message"] = err.Error()
+			return
+		}
+	}
+	// 根据文章的审核规则决定评论是否直接公开
+	comment.Moderate(post)
 	err = comment.Insert()
 	if err != nil {
 		seelog.Errorf("comment insert err: %v", err)
@@ -62,8 +83,19 @@ func CommentPost(c *gin.Context) {
 	}
 
 	seelog.Infof("User[ID:%v] Save Post[ID:%v] comment: %s ", user.ID, pid, content)
+	notifyPendingComment(post, comment, user)
 
 	res["succeed"] = true
+	res["status"] = comment.Status
+}
+
+// 评论待审核时提醒管理员
+func notifyPendingComment(post *models.Post, comment *models.Comment, user *models.User) {
+	if comment.Status != models.CommentStatusPending {
+		return
+	}
+	helpers.NotifyAdmins("Comment awaiting moderation", fmt.Sprintf("%s commented on \"%s\":\n\n%s\n\nModerate: %s\n",
+		user.Username, post.Title, comment.Content, absoluteURL("/admin/comment")))
 }
 
 // TODO 根据ID删除评论
@@ -101,7 +133,7 @@ func CommentDelete(c *gin.Context) {
 	res["succeed"] = true
 }
 
-// TODO
+// 将评论标记为已读，只有文章的作者才能操作
 func CommentRead(c *gin.Context) {
 	var (
 		id  uint
@@ -114,8 +146,16 @@ func CommentRead(c *gin.Context) {
 		res["message"] = err.Error()
 		return
 	}
-	comment := new(models.Comment)
-	comment.ID = id
+	comment, err := models.GetCommentById(id)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	post, err := models.GetAnyPostById(comment.PostID)
+	if err != nil || !currentUser(c).CanModerateComment(post) {
+		res["message"] = "only the author of the post can read this comment"
+		return
+	}
 	err = comment.Update()
 	if err != nil {
 		res["message"] = err.Error()
@@ -123,3 +163,18 @@ func CommentRead(c *gin.Context) {
 	}
 	res["succeed"] = true
 }
+
+// 将当前用户文章下的评论全部标记为已读
+func CommentReadAll(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+	)
+	defer writeJSON(c, res)
+	err = models.ReadAllComment(currentUser(c))
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	res["succeed"] = true
+}
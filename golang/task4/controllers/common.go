@@ -1,17 +1,25 @@
 package controllers
 
 import (
+	"go-blog/models"
+	"go-blog/system"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 const (
-	SessionJwtKey  = "Token"
-	SessionKey     = "UserID"      // session key
-	ContextUserKey = "User"        // context user key
-	SessionCaptcha = "GIN_CAPTCHA" // captcha session key
+	SessionJwtKey     = "Token"
+	SessionRefreshKey = "RefreshToken" // refresh token session key
+	SessionKey        = "UserID"       // session key
+	ContextUserKey    = "User"         // context user key
+	ContextClaimsKey  = "Claims"       // context jwt claims key
+	SessionCaptcha    = "GIN_CAPTCHA"  // captcha session key
+	SessionCSRFKey    = "CSRFToken"    // csrf token session key
 )
 
 func Handle404(c *gin.Context) {
@@ -52,3 +60,62 @@ func writeJSON(ctx *gin.Context, h gin.H) {
 	}
 	ctx.JSON(http.StatusOK, h)
 }
+
+// 读取当前登录用户，未登录时返回 nil
+func currentUser(c *gin.Context) *models.User {
+	userInterface, exists := c.Get(ContextUserKey)
+	if !exists {
+		return nil
+	}
+	user, _ := userInterface.(*models.User)
+	return user
+}
+
+// 每页条数，取自配置文件中的 page_size
+func configPageSize() int {
+	if cfg := system.GetConfiguration(); cfg != nil && cfg.PageSize > 0 {
+		return cfg.PageSize
+	}
+	return 10
+}
+
+func totalPage(total, pageSize int) int {
+	return int(math.Ceil(float64(total) / float64(pageSize)))
+}
+
+// 设置 Last-Modified、ETag 响应头，并在客户端缓存仍然有效时返回 304
+func checkNotModified(c *gin.Context, lastModified time.Time, etag string) bool {
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+	if len(etag) > 0 {
+		c.Header("ETag", etag)
+	}
+
+	// If-None-Match 优先于 If-Modified-Since
+	if match := c.GetHeader("If-None-Match"); len(match) > 0 {
+		if len(etag) > 0 && etagMatch(match, etag) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSRFHeader    = "X-CSRF-Token" // ajax 请求携带 token 的请求头
+	CSRFFormField = "_csrf"        // 表单提交携带 token 的字段
+)
+
+// CSRFToken 读取 session 中的 CSRF token，不存在时生成
+func CSRFToken(c *gin.Context) string {
+	session := sessions.Default(c)
+	if token, _ := session.Get(SessionCSRFKey).(string); len(token) > 0 {
+		return token
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		seelog.Error("CSRFToken rand.Read Error: " + err.Error())
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	session.Set(SessionCSRFKey, token)
+	if err := session.Save(); err != nil {
+		seelog.Error("CSRFToken session.Save Error: " + err.Error())
+	}
+	return token
+}
+
+// VerifyCSRFToken 校验请求头或表单中的 token 与 session 中的是否一致
+func VerifyCSRFToken(c *gin.Context) bool {
+	expected, _ := sessions.Default(c).Get(SessionCSRFKey).(string)
+	if len(expected) == 0 {
+		return false
+	}
+	token := c.GetHeader(CSRFHeader)
+	if len(token) == 0 {
+		token = c.PostForm(CSRFFormField)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// CSRFField 模板函数，输出携带 token 的隐藏表单字段
+func CSRFField(token string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + CSRFFormField + `" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// CSRFMeta 模板函数，输出供 ajax 请求读取 token 的 meta 标签
+func CSRFMeta(token string) template.HTML {
+	return template.HTML(`<meta name="csrf-token" content="` + template.HTMLEscapeString(token) + `">`)
+}
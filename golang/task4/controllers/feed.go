@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 订阅源格式
+const (
+	FeedRSS  = "rss"
+	FeedAtom = "atom"
+	FeedJSON = "json"
+)
+
+var FeedFormats = []string{FeedRSS, FeedAtom, FeedJSON}
+
+// 订阅源中的文章数量
+const feedSize = 20
+
+// 订阅源元数据
+type feedMeta struct {
+	title       string
+	description string
+	link        string // 对应页面的绝对地址
+	self        string // 订阅源自身的绝对地址
+}
+
+// 全站订阅源：/feed.rss、/feed.atom、/feed.json
+func Feed(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := system.GetConfiguration()
+		renderFeed(c, format, models.PostQuery{}, feedMeta{
+			title:       cfg.Title,
+			description: cfg.Title,
+			link:        absoluteURL("/"),
+			self:        absoluteURL(c.Request.URL.Path),
+		})
+	}
+}
+
+// 标签订阅源：/tag/:name/feed.rss 等
+func TagFeed(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag, err := models.GetTagByName(c.Param("name"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		cfg := system.GetConfiguration()
+		renderFeed(c, format, models.PostQuery{Tag: tag.Name}, feedMeta{
+			title:       cfg.Title + " - " + tag.Name,
+			description: "标签：" + tag.Name,
+			link:        absoluteURL("/tag/" + url.PathEscape(tag.Name)),
+			self:        absoluteURL(c.Request.URL.Path),
+		})
+	}
+}
+
+// 作者订阅源：/author/:username/feed.rss 等
+func AuthorFeed(format string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := models.GetUserByUsername(c.Param("username"))
+		if err != nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		cfg := system.GetConfiguration()
+		renderFeed(c, format, models.PostQuery{UserID: user.ID}, feedMeta{
+			title:       cfg.Title + " - " + user.Username,
+			description: "作者：" + user.Username,
+			link:        absoluteURL("/"),
+			self:        absoluteURL(c.Request.URL.Path),
+		})
+	}
+}
+
+func renderFeed(c *gin.Context, format string, query models.PostQuery, meta feedMeta) {
+	posts, err := models.ListPostByQuery(query, 1, feedSize)
+	if err != nil {
+		seelog.Errorf("models.ListPostByQuery err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	updated := lastUpdated(posts)
+	if checkNotModified(c, updated, postsETag(format+meta.self, posts)) {
+		return
+	}
+
+	switch format {
+	case FeedAtom:
+		c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", marshalXML(buildAtom(posts, meta, updated)))
+	case FeedJSON:
+		c.Header("Content-Type", "application/feed+json; charset=utf-8")
+		c.JSON(http.StatusOK, buildJSONFeed(posts, meta))
+	default:
+		c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", marshalXML(buildRSS(posts, meta, updated)))
+	}
+}
+
+// /sitemap.xml
+func SitemapGet(c *gin.Context) {
+	posts, err := models.ListAllPost()
+	if err != nil {
+		seelog.Errorf("models.ListAllPost err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	tags, _ := models.ListTag()
+	categories, _ := models.ListCategory()
+
+	updated := lastUpdated(posts)
+	if checkNotModified(c, updated, postsETag(fmt.Sprintf("sitemap:%d:%d", len(tags), len(categories)), posts)) {
+		return
+	}
+
+	urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: absoluteURL("/"), LastMod: w3cDate(updated), ChangeFreq: "daily", Priority: "1.0"})
+	for _, post := range posts {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{
+			Loc:      postURL(post),
+			LastMod:  w3cDate(post.UpdatedAt),
+			Priority: "0.8",
+		})
+	}
+	for _, category := range categories {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: absoluteURL("/category/" + url.PathEscape(category.Slug)), ChangeFreq: "weekly", Priority: "0.5"})
+	}
+	for _, tag := range tags {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: absoluteURL("/tag/" + url.PathEscape(tag.Name)), ChangeFreq: "weekly", Priority: "0.5"})
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", marshalXML(urlSet))
+}
+
+// 拼接站点域名，生成绝对地址
+func absoluteURL(path string) string {
+	domain := strings.TrimSuffix(system.GetConfiguration().Domain, "/")
+	return domain + path
+}
+
+func postURL(post *models.Post) string {
+	return absoluteURL(fmt.Sprintf("/post/%d", post.ID))
+}
+
+// 渲染 Markdown，并将站内相对地址转换为绝对地址
+func feedContent(post *models.Post) string {
+	renderPost(post)
+	html := post.ContentHTML
+	domain := strings.TrimSuffix(system.GetConfiguration().Domain, "/")
+	return strings.NewReplacer(`src="/`, `src="`+domain+`/`, `href="/`, `href="`+domain+`/`).Replace(html)
+}
+
+func lastUpdated(posts []*models.Post) (updated time.Time) {
+	for _, post := range posts {
+		if post.UpdatedAt.After(updated) {
+			updated = post.UpdatedAt
+		}
+	}
+	return
+}
+
+// 根据文章 ID 与更新时间生成弱 ETag
+func postsETag(scope string, posts []*models.Post) string {
+	h := sha1.New()
+	h.Write([]byte(scope))
+	for _, post := range posts {
+		fmt.Fprintf(h, "|%d:%d", post.ID, post.UpdatedAt.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+func marshalXML(v interface{}) []byte {
+	data, _ := xml.MarshalIndent(v, "", "  ")
+	return append([]byte(xml.Header), data...)
+}
+
+func w3cDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func postAuthor(post *models.Post) string {
+	if len(post.User.Username) > 0 {
+		return post.User.Username
+	}
+	return system.GetConfiguration().Author.Name
+}
+
+// RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string    `xml:"title"`
+	Link           string    `xml:"link"`
+	Description    string    `xml:"description"`
+	AtomLink       atomLink  `xml:"atom:link"`
+	ManagingEditor string    `xml:"managingEditor,omitempty"`
+	LastBuildDate  string    `xml:"lastBuildDate,omitempty"`
+	Items          []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        rssGuid  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func buildRSS(posts []*models.Post, meta feedMeta, updated time.Time) rssFeed {
+	author := system.GetConfiguration().Author
+	channel := rssChannel{
+		Title:       meta.title,
+		Link:        meta.link,
+		Description: meta.description,
+		AtomLink:    atomLink{Href: meta.self, Rel: "self", Type: "application/rss+xml"},
+	}
+	if len(author.Email) > 0 {
+		channel.ManagingEditor = fmt.Sprintf("%s (%s)", author.Email, author.Name)
+	}
+	if !updated.IsZero() {
+		channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, post := range posts {
+		item := rssItem{
+			Title:       post.Title,
+			Link:        postURL(post),
+			Guid:        rssGuid{IsPermaLink: true, Value: postURL(post)},
+			PubDate:     post.CreatedAt.UTC().Format(time.RFC1123Z),
+			Author:      postAuthor(post),
+			Description: feedContent(post),
+		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, tag.Name)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	return rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Dc:      "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	}
+}
+
+// Atom 1.0
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+func buildAtom(posts []*models.Post, meta feedMeta, updated time.Time) atomFeed {
+	author := system.GetConfiguration().Author
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed := atomFeed{
+		Title:   meta.title,
+		ID:      meta.self,
+		Updated: w3cDate(updated),
+		Links: []atomLink{
+			{Href: meta.self, Rel: "self", Type: "application/atom+xml"},
+			{Href: meta.link, Rel: "alternate", Type: "text/html"},
+		},
+		Author: &atomAuthor{Name: author.Name, Email: author.Email},
+	}
+	for _, post := range posts {
+		entry := atomEntry{
+			Title:     post.Title,
+			ID:        postURL(post),
+			Link:      atomLink{Href: postURL(post), Rel: "alternate", Type: "text/html"},
+			Published: w3cDate(post.CreatedAt),
+			Updated:   w3cDate(post.UpdatedAt),
+			Author:    atomAuthor{Name: postAuthor(post)},
+			Content:   atomContent{Type: "html", Value: feedContent(post)},
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag.Name})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// JSON Feed 1.1
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+func buildJSONFeed(posts []*models.Post, meta feedMeta) jsonFeed {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       meta.title,
+		HomePageURL: meta.link,
+		FeedURL:     meta.self,
+		Description: meta.description,
+		Authors:     []jsonAuthor{{Name: system.GetConfiguration().Author.Name}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, post := range posts {
+		item := jsonFeedItem{
+			ID:            postURL(post),
+			URL:           postURL(post),
+			Title:         post.Title,
+			ContentHTML:   feedContent(post),
+			DatePublished: w3cDate(post.CreatedAt),
+			DateModified:  w3cDate(post.UpdatedAt),
+			Authors:       []jsonAuthor{{Name: postAuthor(post)}},
+		}
+		for _, tag := range post.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
+
+// sitemap.xml
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
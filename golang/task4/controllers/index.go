@@ -1,23 +1,21 @@
 package controllers
 
 import (
+	"encoding/base64"
 	"go-blog/models"
-	"math"
 	"net/http"
 	"strconv"
 
 	"github.com/cihub/seelog"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/russross/blackfriday"
 )
 
 func IndexGet(c *gin.Context) {
 	var (
 		pageIndex int
-		pageSize  = 10
+		pageSize  = configPageSize()
 		total     int
-		page      string
 		err       error
 		posts     []*models.Post
 	)
@@ -32,14 +30,10 @@ func IndexGet(c *gin.Context) {
 		}
 	}
 
-	page = c.Query("page")
-	pageIndex, _ = strconv.Atoi(page)
-	if pageIndex <= 0 {
-		pageIndex = 1
-	}
-	posts, err = models.ListAllPost()
+	pageIndex = queryPageIndex(c)
+	posts, err = models.ListPost(pageIndex, pageSize)
 	if err != nil {
-		seelog.Errorf("models.ListAllPost err: %v", err)
+		seelog.Errorf("models.ListPost err: %v", err)
 		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
@@ -50,18 +44,163 @@ func IndexGet(c *gin.Context) {
 		return
 	}
 
+	renderPostList(c, posts, total, pageIndex, pageSize, gin.H{
+		"user": loginUser,
+	})
+}
+
+// 按标签筛选的文章列表
+func TagGet(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+		name      = c.Param("name")
+	)
+	tag, err := models.GetTagByName(name)
+	if err != nil {
+		Handle404(c)
+		return
+	}
+	posts, err := models.ListPostByTag(tag.Name, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListPostByTag err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	total, err := models.CountPostByTag(tag.Name)
+	if err != nil {
+		seelog.Errorf("models.CountPostByTag err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	renderPostList(c, posts, total, pageIndex, pageSize, gin.H{
+		"user":  currentUser(c),
+		"tag":   tag,
+		"title": "标签：" + tag.Name,
+	})
+}
+
+// 按分类筛选的文章列表
+func CategoryGet(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+		slug      = c.Param("slug")
+	)
+	category, err := models.GetCategoryBySlug(slug)
+	if err != nil {
+		Handle404(c)
+		return
+	}
+	posts, err := models.ListPostByCategory(category.Slug, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListPostByCategory err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	total, err := models.CountPostByCategory(category.Slug)
+	if err != nil {
+		seelog.Errorf("models.CountPostByCategory err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	renderPostList(c, posts, total, pageIndex, pageSize, gin.H{
+		"user":     currentUser(c),
+		"category": category,
+		"title":    "分类：" + category.Name,
+	})
+}
+
+func queryPageIndex(c *gin.Context) int {
+	pageIndex, _ := strconv.Atoi(c.Query("page"))
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+	return pageIndex
+}
+
+// 渲染文章列表页，附带分页与侧边栏数据
+func renderPostList(c *gin.Context, posts []*models.Post, total, pageIndex, pageSize int, data gin.H) {
 	for _, post := range posts {
-		post.Content = string(blackfriday.MarkdownCommon([]byte(post.Content)))
+		renderPost(post)
 	}
+	data["posts"] = posts
+	data["pageIndex"] = pageIndex
+	data["totalPage"] = totalPage(total, pageSize)
+	data["path"] = c.Request.URL.Path
+	for key, value := range cachedSidebarData() {
+		data[key] = value
+	}
+	c.HTML(http.StatusOK, "index/index.html", data)
+}
+
+// 侧边栏：归档、评论最多、阅读最多、分类与标签
+func sidebarData() gin.H {
 	postArchives, _ := models.ListPostArchives()
 	maxCommentPost, _ := models.ListMaxCommentPost()
-	c.HTML(http.StatusOK, "index/index.html", gin.H{
-		"posts":           posts,
+	mostReadPost, _ := models.ListMostReadPost(mostReadDays, 5)
+	tags, _ := models.ListTag()
+	categories, _ := models.ListCategory()
+	return gin.H{
 		"archives":        postArchives,
-		"user":            loginUser,
-		"pageIndex":       pageIndex,
-		"totalPage":       int(math.Ceil(float64(total) / float64(pageSize))),
-		"path":            c.Request.URL.Path,
 		"maxCommentPosts": maxCommentPost,
-	})
+		"mostReadPosts":   mostReadPost,
+		"tags":            tags,
+		"categories":      categories,
+	}
+}
+
+// 文章列表 JSON 接口，采用游标分页：?cursor=<next_cursor>&size=10
+func PostListJSON(c *gin.Context) {
+	var (
+		afterID  uint
+		pageSize = configPageSize()
+		err      error
+	)
+	if cursor := c.Query("cursor"); len(cursor) > 0 {
+		afterID, err = decodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.BaseResponse{Code: http.StatusBadRequest, Msg: "cursor invalid"})
+			return
+		}
+	}
+	if size, _ := strconv.Atoi(c.Query("size")); size > 0 && size <= 100 {
+		pageSize = size
+	}
+
+	// 多查一条用于判断是否还有下一页
+	posts, err := models.ListPostAfter(afterID, pageSize+1)
+	if err != nil {
+		seelog.Errorf("models.ListPostAfter err: %v", err)
+		c.JSON(http.StatusInternalServerError, models.BaseResponse{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	resp := models.CursorResponse[*models.Post]{
+		BaseResponse: models.BaseResponse{Code: http.StatusOK, Msg: "success"},
+		Payload:      posts,
+		Size:         int64(pageSize),
+	}
+	if len(posts) > pageSize {
+		resp.Payload = posts[:pageSize]
+		resp.NextCursor = encodeCursor(resp.Payload[pageSize-1].ID)
+	}
+	if resp.Payload == nil {
+		resp.Payload = []*models.Post{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 游标对客户端不透明，内容为最后一条记录的 ID
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return parseUint(string(data))
 }
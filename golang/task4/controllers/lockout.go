@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"go-blog/models"
+	"net/http"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 登录锁定记录列表
+func LockoutIndex(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	user := currentUser(c)
+	events, err := models.ListLockoutEvent(pageIndex, pageSize)
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	total, _ := models.CountLockoutEvent()
+	comments, _ := models.ListUnreadComment(user)
+	c.HTML(http.StatusOK, "admin/lockout.html", gin.H{
+		"csrf":      CSRFToken(c),
+		"events":    events,
+		"now":       time.Now(),
+		"comments":  comments,
+		"user":      user,
+		"Active":    "lockouts",
+		"pageIndex": pageIndex,
+		"totalPage": totalPage(int(total), pageSize),
+		"path":      c.Request.URL.Path,
+	})
+}
+
+// 解除登录锁定
+func LockoutClear(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+	)
+	defer writeJSON(c, res)
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	event, err := models.GetLockoutEventById(id)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	user := currentUser(c)
+	err = event.Clear(user.ID)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] cleared lockout of %s %s", user.ID, event.Kind, event.Key)
+	res["succeed"] = true
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 渲染文章内容；结果保存到数据库，内容修改前直接使用
+func renderPost(post *models.Post) {
+	if len(post.ContentHTML) > 0 || len(post.Content) == 0 {
+		return
+	}
+	rendered := helpers.RenderMarkdown(post.Content)
+	post.ContentHTML, post.TOC = rendered.HTML, rendered.TOCJSON()
+	if err := post.SaveRendered(); err != nil {
+		seelog.Errorf("post.SaveRendered err: %v", err)
+	}
+}
+
+// 文章目录，供模板使用
+func postTOC(post *models.Post) []helpers.TOCItem {
+	return helpers.ParseTOC(post.TOC)
+}
+
+// POST /admin/preview 编辑器预览，与文章页使用同一渲染器
+func Preview(c *gin.Context) {
+	res := gin.H{}
+	defer writeJSON(c, res)
+	rendered := helpers.RenderMarkdown(c.PostForm("content"))
+	res["succeed"] = true
+	res["html"] = rendered.HTML
+	res["toc"] = rendered.TOC
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/models"
+	"go-blog/system"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 保存上传的文件并记录附件
+func saveUpload(user *models.User, file multipart.File, fh *multipart.FileHeader) (*models.Attachment, *StoredFile, error) {
+	cfg := system.GetConfiguration()
+	uploader, err := newUploader(cfg)
+	if err != nil {
+		seelog.Error(err)
+		return nil, nil, err
+	}
+	stored, err := StoreFile(uploader, file, cfg.Upload)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachment := &models.Attachment{
+		UserID:      user.ID,
+		Hash:        stored.Hash,
+		Key:         stored.Key,
+		URL:         stored.URL,
+		Filename:    filepath.Base(fh.Filename),
+		ContentType: stored.ContentType,
+		Size:        stored.Size,
+		Width:       stored.Width,
+		Height:      stored.Height,
+	}
+	variants := make([]models.AttachmentVariant, 0, len(stored.Variants))
+	for _, v := range stored.Variants {
+		variants = append(variants, models.AttachmentVariant{Name: v.Name, Key: v.Key, URL: v.URL, Width: v.Width, Height: v.Height})
+	}
+	attachment.SetVariants(variants)
+	if attachment, err = models.SaveAttachment(attachment); err != nil {
+		seelog.Errorf("models.SaveAttachment err: %v", err)
+		return nil, nil, err
+	}
+	return attachment, stored, nil
+}
+
+// 删除附件记录及存储中的文件
+func removeAttachment(attachment *models.Attachment) error {
+	if err := attachment.Purge(); err != nil {
+		return err
+	}
+	uploader, err := newUploader(system.GetConfiguration())
+	if err != nil {
+		return err
+	}
+	for _, key := range attachment.Keys() {
+		if err = uploader.Delete(key); err != nil {
+			seelog.Errorf("delete attachment file %s err: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// 可以管理全部文章的用户可查看全部附件，否则只能查看自己上传的附件
+func mediaOwnerFilter(user *models.User) uint {
+	if user.Can(models.PermPostEditAny) {
+		return 0
+	}
+	return user.ID
+}
+
+func canManageAttachment(user *models.User, attachment *models.Attachment) bool {
+	return attachment.UserID == user.ID || user.Can(models.PermPostEditAny)
+}
+
+// 媒体库
+func MediaIndex(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	user := currentUser(c)
+	attachments, err := models.ListAttachment(mediaOwnerFilter(user), pageIndex, pageSize)
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	total, _ := models.CountAttachment(mediaOwnerFilter(user))
+	comments, _ := models.ListUnreadComment(user)
+	c.HTML(http.StatusOK, "admin/media.html", gin.H{
+		"csrf":        CSRFToken(c),
+		"attachments": attachments,
+		"comments":    comments,
+		"user":        user,
+		"Active":      "media",
+		"pageIndex":   pageIndex,
+		"totalPage":   totalPage(int(total), pageSize),
+		"path":        c.Request.URL.Path,
+	})
+}
+
+// 删除未被引用的附件
+func MediaDelete(c *gin.Context) {
+	res := gin.H{}
+	defer writeJSON(c, res)
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	attachment, err := models.GetAttachment(id)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	user := currentUser(c)
+	if !canManageAttachment(user, attachment) {
+		res["message"] = "permission denied"
+		return
+	}
+	if err = removeAttachment(attachment); err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] deleted attachment %s", user.ID, attachment.Key)
+	res["succeed"] = true
+}
+
+// GET /api/v1/attachments?page=1&size=10
+func APIAttachmentList(c *gin.Context) {
+	user := currentUser(c)
+	pageIndex, pageSize := apiPageParams(c)
+	attachments, err := models.ListAttachment(mediaOwnerFilter(user), pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.ListAttachment err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	total, err := models.CountAttachment(mediaOwnerFilter(user))
+	if err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]models.AttachmentData, 0, len(attachments))
+	for _, attachment := range attachments {
+		payload = append(payload, models.NewAttachmentData(attachment))
+	}
+	apiPage(c, payload, total, pageIndex, pageSize)
+}
+
+// GET /api/v1/attachments/:id 附件详情，包含引用该附件的文章
+func APIAttachmentGet(c *gin.Context) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return
+	}
+	attachment, err := models.GetAttachment(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "attachment not found")
+		return
+	}
+	if !canManageAttachment(currentUser(c), attachment) {
+		apiError(c, CodeForbidden, "permission denied")
+		return
+	}
+	posts, err := attachment.ReferencedPosts()
+	if err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	postIDs := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+	apiData(c, CodeSuccess, gin.H{
+		"attachment": models.NewAttachmentData(attachment),
+		"post_ids":   postIDs,
+	})
+}
+
+// POST /api/v1/attachments 上传附件，表单字段为 file
+func APIAttachmentCreate(c *gin.Context) {
+	file, fh, err := c.Request.FormFile("file")
+	if err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	attachment, _, err := saveUpload(currentUser(c), file, fh)
+	if err != nil {
+		apiError(c, CodeBadRequest, err.Error())
+		return
+	}
+	attachment, _ = models.GetAttachment(attachment.ID)
+	apiData(c, CodeCreated, models.NewAttachmentData(attachment))
+}
+
+// DELETE /api/v1/attachments/:id 删除未被引用的附件
+func APIAttachmentDelete(c *gin.Context) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		apiError(c, CodeBadRequest, "id invalid")
+		return
+	}
+	attachment, err := models.GetAttachment(id)
+	if err != nil {
+		apiError(c, CodeNotFound, "attachment not found")
+		return
+	}
+	if !canManageAttachment(currentUser(c), attachment) {
+		apiError(c, CodeForbidden, "permission denied")
+		return
+	}
+	if err = removeAttachment(attachment); err != nil {
+		if errors.Is(err, models.ErrAttachmentInUse) {
+			apiError(c, CodeConflict, err.Error())
+			return
+		}
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	apiData(c, CodeSuccess, gin.H{"id": attachment.ID})
+}
+
+// CollectOrphanAttachments 删除没有被文章引用且超过保留期的附件，返回删除数量
+func CollectOrphanAttachments(now time.Time) (int, error) {
+	grace := time.Duration(system.GetConfiguration().Upload.OrphanGraceSeconds) * time.Second
+	attachments, err := models.ListOrphanAttachment(now.Add(-grace))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, attachment := range attachments {
+		// 查询之后可能又被文章引用
+		if err = removeAttachment(attachment); err != nil {
+			if !errors.Is(err, models.ErrAttachmentInUse) {
+				seelog.Errorf("remove attachment %s err: %v", attachment.Key, err)
+			}
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"go-blog/models"
+	"net/http"
+	"strconv"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 评论审核状态筛选项，all 表示全部
+var moderationStatuses = []string{
+	models.CommentStatusPending,
+	models.CommentStatusApproved,
+	models.CommentStatusSpam,
+	models.CommentStatusRejected,
+	"all",
+}
+
+// 评论审核列表：当前用户文章下的评论，默认显示待审核的评论
+func CommentIndex(c *gin.Context) {
+	var (
+		user      = currentUser(c)
+		status    = c.DefaultQuery("status", models.CommentStatusPending)
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	if !models.IsCommentStatus(status) {
+		status = "all"
+	}
+	filter := status
+	if filter == "all" {
+		filter = ""
+	}
+	moderations, err := models.ListModerationComment(user, filter, pageIndex, pageSize)
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	total, _ := models.CountModerationComment(user, filter)
+	counts := make(map[string]int64, len(moderationStatuses))
+	for _, s := range moderationStatuses {
+		if s == "all" {
+			counts[s], _ = models.CountModerationComment(user, "")
+		} else {
+			counts[s], _ = models.CountModerationComment(user, s)
+		}
+	}
+	comments, _ := models.ListUnreadComment(user)
+	c.HTML(http.StatusOK, "admin/comment.html", gin.H{
+		"csrf":        CSRFToken(c),
+		"moderations": moderations,
+		"statuses":    moderationStatuses,
+		"counts":      counts,
+		"status":      status,
+		"comments":    comments,
+		"user":        user,
+		"Active":      "comments",
+		"pageIndex":   pageIndex,
+		"totalPage":   totalPage(int(total), pageSize),
+		"path":        c.Request.URL.Path,
+	})
+}
+
+// 批量审核评论，表单参数 ids 为评论 ID，status 为目标状态
+func CommentModerate(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+		ids []uint
+	)
+	defer writeJSON(c, res)
+	for _, value := range c.PostFormArray("ids") {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			res["message"] = "id invalid: " + value
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+	user := currentUser(c)
+	count, err := models.UpdateCommentStatus(user, ids, c.PostForm("status"))
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] moderate %d comment(s) as %s", user.ID, count, c.PostForm("status"))
+	res["succeed"] = true
+	res["count"] = count
+}
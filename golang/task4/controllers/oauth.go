@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+const (
+	SessionOAuthState = "OAuthState" // oauth state session key，值为 {provider}:{state}
+	SessionOAuthLink  = "OAuthLink"  // 绑定第三方账号的用户 ID
+)
+
+type GithubUserInfo struct {
+	AvatarURL string      `json:"avatar_url"`
+	Blog      string      `json:"blog"`
+	CreatedAt string      `json:"created_at"`
+	Email     interface{} `json:"email"`
+	HTMLURL   string      `json:"html_url"`
+	ID        int         `json:"id"`
+	Login     string      `json:"login"`
+	Name      interface{} `json:"name"`
+	UpdatedAt string      `json:"updated_at"`
+	URL       string      `json:"url"`
+}
+
+// GitHub /user/emails 接口返回的邮箱
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// OIDC userinfo 接口返回的用户信息
+type oidcUserInfo struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Picture           string `json:"picture"`
+}
+
+type oauthToken struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// 读取配置中的第三方登录，github 未配置端点时使用官方地址
+func oauthProvider(name string) (*system.OAuthProvider, bool) {
+	cfg := system.GetConfiguration()
+	if cfg == nil {
+		return nil, false
+	}
+	for _, item := range cfg.OAuth {
+		if item.Name != name {
+			continue
+		}
+		provider := item
+		switch provider.Type {
+		case "github":
+			provider.AuthURL = withDefault(provider.AuthURL, "https://github.com/login/oauth/authorize")
+			provider.TokenURL = withDefault(provider.TokenURL, "https://github.com/login/oauth/access_token")
+			provider.UserInfoURL = withDefault(provider.UserInfoURL, "https://api.github.com/user")
+			provider.EmailsURL = withDefault(provider.EmailsURL, "https://api.github.com/user/emails")
+			if len(provider.Scopes) == 0 {
+				provider.Scopes = []string{"read:user", "user:email"}
+			}
+		case "oidc":
+			if len(provider.Scopes) == 0 {
+				provider.Scopes = []string{"openid", "email", "profile"}
+			}
+		default:
+			return nil, false
+		}
+		if len(provider.RedirectURL) == 0 {
+			provider.RedirectURL = absoluteURL("/oauth/" + provider.Name + "/callback")
+		}
+		return &provider, true
+	}
+	return nil, false
+}
+
+func withDefault(value, def string) string {
+	if len(value) == 0 {
+		return def
+	}
+	return value
+}
+
+// 登录页按钮使用的第三方登录列表
+type OAuthButton struct {
+	Name  string
+	Title string
+}
+
+// OAuthProviders 模板函数，返回已配置的第三方登录
+func OAuthProviders() []OAuthButton {
+	cfg := system.GetConfiguration()
+	if cfg == nil {
+		return nil
+	}
+	links := make([]OAuthButton, 0, len(cfg.OAuth))
+	for _, item := range cfg.OAuth {
+		links = append(links, OAuthButton{Name: item.Name, Title: withDefault(item.Title, item.Name)})
+	}
+	return links
+}
+
+// 生成 state 并保存到 session，跳转到第三方授权页面
+func redirectAuthorize(c *gin.Context, provider *system.OAuthProvider, linkUserID uint) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	state := base64.RawURLEncoding.EncodeToString(buf)
+	session := sessions.Default(c)
+	session.Set(SessionOAuthState, provider.Name+":"+state)
+	if linkUserID > 0 {
+		session.Set(SessionOAuthLink, linkUserID)
+	} else {
+		session.Delete(SessionOAuthLink)
+	}
+	if err := session.Save(); err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {provider.ClientID},
+		"redirect_uri":  {provider.RedirectURL},
+		"scope":         {strings.Join(provider.Scopes, " ")},
+		"state":         {state},
+	}
+	sep := "?"
+	if strings.Contains(provider.AuthURL, "?") {
+		sep = "&"
+	}
+	c.Redirect(http.StatusFound, provider.AuthURL+sep+query.Encode())
+}
+
+// GET /oauth/:provider 第三方登录
+func OAuthLogin(c *gin.Context) {
+	provider, ok := oauthProvider(c.Param("provider"))
+	if !ok {
+		Handle404(c)
+		return
+	}
+	redirectAuthorize(c, provider, 0)
+}
+
+// GET /admin/oauth/:provider/link 为当前用户绑定第三方账号
+func OAuthLink(c *gin.Context) {
+	provider, ok := oauthProvider(c.Param("provider"))
+	if !ok {
+		Handle404(c)
+		return
+	}
+	user := currentUser(c)
+	if user == nil {
+		c.Redirect(http.StatusFound, "/signin")
+		return
+	}
+	redirectAuthorize(c, provider, user.ID)
+}
+
+// GET /oauth/:provider/callback 校验 state，使用授权码换取访问令牌并读取用户信息
+func OAuthCallback(c *gin.Context) {
+	provider, ok := oauthProvider(c.Param("provider"))
+	if !ok {
+		Handle404(c)
+		return
+	}
+	session := sessions.Default(c)
+	expected, _ := session.Get(SessionOAuthState).(string)
+	linkUserID, _ := session.Get(SessionOAuthLink).(uint)
+	// state 只能使用一次
+	session.Delete(SessionOAuthState)
+	session.Delete(SessionOAuthLink)
+	_ = session.Save()
+
+	state := provider.Name + ":" + c.Query("state")
+	if len(expected) == 0 || subtle.ConstantTimeCompare([]byte(state), []byte(expected)) != 1 {
+		seelog.Warnf("OAuth %s state mismatch from %s", provider.Name, c.ClientIP())
+		HandleMessage(c, "oauth state invalid, please try again")
+		return
+	}
+	if errCode := c.Query("error"); len(errCode) > 0 {
+		HandleMessage(c, "oauth authorization failed: "+errCode)
+		return
+	}
+
+	accessToken, err := exchangeOAuthCode(provider, c.Query("code"))
+	if err != nil {
+		seelog.Errorf("OAuth %s exchange code err: %v", provider.Name, err)
+		HandleMessage(c, "oauth authorization failed")
+		return
+	}
+	ext, err := fetchOAuthUser(provider, accessToken)
+	if err != nil {
+		seelog.Errorf("OAuth %s fetch user err: %v", provider.Name, err)
+		HandleMessage(c, "oauth authorization failed")
+		return
+	}
+
+	// 绑定到当前用户
+	if linkUserID > 0 {
+		user, err := models.GetUser(linkUserID)
+		if err == nil {
+			err = user.LinkIdentity(ext)
+		}
+		if err != nil {
+			HandleMessage(c, err.Error())
+			return
+		}
+		seelog.Infof("User[ID:%v] linked %s account %s", user.ID, provider.Name, ext.Login)
+		c.Redirect(http.StatusFound, "/admin/index")
+		return
+	}
+
+	user, created, err := models.LoginWithIdentity(ext)
+	if err != nil {
+		seelog.Errorf("OAuth %s login err: %v", provider.Name, err)
+		HandleMessage(c, err.Error())
+		return
+	}
+	if user.Locked {
+		HandleMessage(c, models.ErrUserLocked.Error())
+		return
+	}
+	if created {
+		seelog.Infof("User[ID:%v] registered via %s account %s", user.ID, provider.Name, ext.Login)
+	}
+
+	// 登录后更换 CSRF token
+	session.Delete(SessionCSRFKey)
+	data, err := issueLoginData(c, user)
+	if err != nil {
+		HandleMessage(c, "Failed to generate token")
+		return
+	}
+	saveSessionTokens(c, data)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// 使用授权码换取访问令牌
+func exchangeOAuthCode(provider *system.OAuthProvider, code string) (string, error) {
+	if len(code) == 0 {
+		return "", errors.New("authorization code is empty")
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURL},
+		"client_id":     {provider.ClientID},
+		"client_secret": {provider.ClientSecret},
+	}
+	req, err := http.NewRequest(http.MethodPost, provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token oauthToken
+	if err = doOAuthRequest(req, &token); err != nil {
+		return "", err
+	}
+	if len(token.Error) > 0 {
+		return "", fmt.Errorf("%s: %s", token.Error, token.ErrorDescription)
+	}
+	if len(token.AccessToken) == 0 {
+		return "", errors.New("access token is empty")
+	}
+	return token.AccessToken, nil
+}
+
+// 读取第三方用户信息
+func fetchOAuthUser(provider *system.OAuthProvider, accessToken string) (*models.ExternalUser, error) {
+	ext := &models.ExternalUser{Provider: provider.Name}
+	if provider.Type == "github" {
+		var info GithubUserInfo
+		if err := getOAuthJSON(provider.UserInfoURL, accessToken, &info); err != nil {
+			return nil, err
+		}
+		if info.ID == 0 {
+			return nil, errors.New("github user id is empty")
+		}
+		ext.Subject = strconv.Itoa(info.ID)
+		ext.Login = info.Login
+		ext.Name, _ = info.Name.(string)
+		ext.Email, _ = info.Email.(string)
+		ext.AvatarUrl = info.AvatarURL
+		// 公开邮箱不一定已验证，以 /user/emails 中已验证的主邮箱为准
+		var emails []githubEmail
+		if err := getOAuthJSON(provider.EmailsURL, accessToken, &emails); err == nil {
+			for _, email := range emails {
+				if email.Primary && email.Verified {
+					ext.Email, ext.EmailVerified = email.Email, true
+				}
+			}
+		}
+		return ext, nil
+	}
+	var info oidcUserInfo
+	if err := getOAuthJSON(provider.UserInfoURL, accessToken, &info); err != nil {
+		return nil, err
+	}
+	if len(info.Subject) == 0 {
+		return nil, errors.New("oidc subject is empty")
+	}
+	ext.Subject = info.Subject
+	ext.Login = withDefault(info.PreferredUsername, info.Name)
+	ext.Name = info.Name
+	ext.Email = info.Email
+	ext.EmailVerified = info.EmailVerified
+	ext.AvatarUrl = info.Picture
+	return ext, nil
+}
+
+func getOAuthJSON(endpoint, accessToken string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return doOAuthRequest(req, v)
+}
+
+func doOAuthRequest(req *http.Request, v interface{}) error {
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
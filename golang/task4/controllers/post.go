@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"go-blog/jobs"
 	"go-blog/models"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,25 +21,35 @@ func PostGet(c *gin.Context) {
 		Handle404(c)
 		return
 	}
-	post.View++
-	post.Comments, _ = models.ListCommentByPostID(id)
+	// 加上尚未写入数据库的阅读数
+	post.View += jobs.PendingViews(post.ID)
+	renderPost(post)
+	comments, _ := models.ListCommentTreeByPostID(id)
 	userInterface, exists := c.Get(ContextUserKey)
 	if exists {
 		user, _ := userInterface.(*models.User)
 		c.HTML(http.StatusOK, "post/display.html", gin.H{
-			"post": post,
-			"user": user,
+			"csrf":     CSRFToken(c),
+			"post":     post,
+			"toc":      postTOC(post),
+			"comments": comments,
+			"user":     user,
 		})
 	} else {
+		// 匿名访问的页面会被缓存，不包含会话相关的 token
 		c.HTML(http.StatusOK, "post/display.html", gin.H{
-			"post": post,
-			"user": nil,
+			"csrf":     "",
+			"post":     post,
+			"toc":      postTOC(post),
+			"comments": comments,
+			"user":     nil,
 		})
 	}
 }
 
 func PostNew(c *gin.Context) {
 	c.HTML(http.StatusOK, "post/new.html", gin.H{
+		"csrf": CSRFToken(c),
 		"user": c.MustGet(ContextUserKey),
 	})
 }
@@ -55,9 +67,22 @@ func PostCreate(c *gin.Context) {
 		UserID:  user.ID,
 		View:    0,
 	}
-	err := post.Insert()
+	err := bindTaxonomy(c, post)
+	if err == nil {
+		err = bindStatus(c, post)
+	}
+	if err == nil {
+		err = bindCommentApproval(c, post)
+	}
+	if err == nil {
+		err = post.Insert()
+	}
+	if err == nil {
+		err = post.SaveRevision(user.ID)
+	}
 	if err != nil {
 		c.HTML(http.StatusOK, "post/new.html", gin.H{
+			"csrf":    CSRFToken(c),
 			"post":    post,
 			"message": err.Error(),
 			"user":    user,
@@ -65,6 +90,9 @@ func PostCreate(c *gin.Context) {
 		return
 	}
 
+	if post.Status == models.PostStatusScheduled {
+		jobs.WakePublisher()
+	}
 	c.Redirect(http.StatusMovedPermanently, "/admin/post")
 }
 
@@ -76,15 +104,16 @@ func PostEdit(c *gin.Context) {
 		HandleMessage(c, err.Error())
 		return
 	}
-	post, err := models.GetPostById(id)
+	post, err := models.GetAnyPostById(id)
 	if err != nil {
 		Handle404(c)
 		return
 	}
 
-	// 首先验证是否具备编辑权限：只有文章的作者才能更新自己的文章
-	if post.UserID == user.ID {
+	// 首先验证是否具备编辑权限：文章的作者或编辑、管理员才能更新文章
+	if user.CanEditPost(post) {
 		c.HTML(http.StatusOK, "post/modify.html", gin.H{
+			"csrf": CSRFToken(c),
 			"post": post,
 			"user": user,
 		})
@@ -109,19 +138,46 @@ func PostUpdate(c *gin.Context) {
 		return
 	}
 
-	exist, err := models.GetPostById(id)
+	exist, err := models.GetAnyPostById(id)
 	if err != nil {
 		Handle404(c)
+		return
 	}
-	if exist.UserID == user.ID {
+	if user.CanEditPost(exist) {
 		post := &models.Post{
 			Title:   title,
 			Content: content,
 		}
 		post.ID = id
-		err = post.Update()
+		// 保存前记录修订版本，避免误保存丢失原文
+		err = exist.EnsureRevision()
+		if err == nil {
+			err = post.Update()
+		}
+		if err == nil {
+			err = post.SaveRevision(user.ID)
+		}
+		if err == nil {
+			err = updateTaxonomy(c, post)
+		}
+		if err == nil {
+			err = bindStatus(c, exist)
+		}
+		if err == nil {
+			err = exist.UpdateStatus()
+		}
+		if err == nil {
+			err = bindCommentApproval(c, exist)
+		}
+		if err == nil {
+			err = exist.UpdateCommentApproval()
+		}
+		if err == nil && exist.Status == models.PostStatusScheduled {
+			jobs.WakePublisher()
+		}
 		if err != nil {
 			c.HTML(http.StatusOK, "post/modify.html", gin.H{
+				"csrf":    CSRFToken(c),
 				"post":    post,
 				"message": err.Error(),
 				"user":    user,
@@ -149,12 +205,16 @@ func PostPublish(c *gin.Context) {
 		res["message"] = err.Error()
 		return
 	}
-	post, err = models.GetPostById(id)
+	post, err = models.GetAnyPostById(id)
 	if err != nil {
 		res["message"] = err.Error()
 		return
 	}
-	err = post.Update()
+	if !currentUser(c).CanEditPost(post) {
+		res["message"] = "《" + post.Title + "》只有文章的作者才能发布自己的文章"
+		return
+	}
+	err = post.Publish()
 	if err != nil {
 		res["message"] = err.Error()
 		return
@@ -177,11 +237,12 @@ func PostDelete(c *gin.Context) {
 		return
 	}
 
-	exist, err := models.GetPostById(id)
+	exist, err := models.GetAnyPostById(id)
 	if err != nil {
-		Handle404(c)
+		res["message"] = err.Error()
+		return
 	}
-	if exist.UserID == user.ID {
+	if user.CanEditPost(exist) {
 		post := &models.Post{}
 		post.ID = id
 		err = post.LogicDelete()
@@ -199,14 +260,67 @@ func PostDelete(c *gin.Context) {
 }
 
 func PostIndex(c *gin.Context) {
-	posts, _ := models.ListAllPost()
-	comments, _ := models.ListAllComment()
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	query := models.PostQuery{Status: models.PostStatusAll}
+	posts, _ := models.ListPostByQuery(query, pageIndex, pageSize)
+	total, _ := models.CountPostByQuery(query)
 	userInterface := c.MustGet(ContextUserKey)
 	user, _ := userInterface.(*models.User)
+	comments, _ := models.ListUnreadComment(user)
 	c.HTML(http.StatusOK, "admin/post.html", gin.H{
-		"posts":    posts,
-		"Active":   "posts",
-		"user":     user,
-		"comments": comments,
+		"csrf":      CSRFToken(c),
+		"posts":     posts,
+		"Active":    "posts",
+		"user":      user,
+		"comments":  comments,
+		"pageIndex": pageIndex,
+		"totalPage": totalPage(int(total), pageSize),
+		"path":      c.Request.URL.Path,
 	})
 }
+
+// 根据表单中的 tags、categories 字段设置文章的标签与分类
+func bindTaxonomy(c *gin.Context, post *models.Post) (err error) {
+	post.Tags, err = models.GetOrCreateTags(models.SplitNames(c.PostForm("tags")))
+	if err != nil {
+		return
+	}
+	post.Categories, err = models.GetOrCreateCategories(models.SplitNames(c.PostForm("categories")))
+	return
+}
+
+func updateTaxonomy(c *gin.Context, post *models.Post) error {
+	if err := bindTaxonomy(c, post); err != nil {
+		return err
+	}
+	if err := post.ReplaceTags(post.Tags); err != nil {
+		return err
+	}
+	return post.ReplaceCategories(post.Categories)
+}
+
+// 根据表单中的 status、published_at 字段设置文章状态，发布时间格式为 2006-01-02T15:04
+func bindStatus(c *gin.Context, post *models.Post) error {
+	var publishAt *time.Time
+	if value := c.PostForm("published_at"); len(value) > 0 {
+		t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+		if err != nil {
+			return err
+		}
+		publishAt = &t
+	}
+	return post.SetStatus(c.PostForm("status"), publishAt)
+}
+
+// 根据表单中的 comment_approval 字段设置评论审核规则，默认受信任的评论者自动通过
+func bindCommentApproval(c *gin.Context, post *models.Post) error {
+	approval := c.DefaultPostForm("comment_approval", models.CommentApprovalTrusted)
+	if !models.IsCommentApproval(approval) {
+		return models.ErrCommentApprovalInvalid
+	}
+	post.CommentApproval = approval
+	return nil
+}
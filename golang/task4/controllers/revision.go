@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 文章修订记录列表，from、to 为需要对比的两个修订版本 ID，默认对比最近两次修改
+func PostRevisions(c *gin.Context) {
+	user := currentUser(c)
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	post, err := models.GetAnyPostById(id)
+	if err != nil {
+		Handle404(c)
+		return
+	}
+	if !user.CanEditPost(post) {
+		c.HTML(http.StatusOK, "errors/error.html", gin.H{
+			"user":    user,
+			"message": "《" + post.Title + "》只有文章的作者才能查看修订记录",
+		})
+		return
+	}
+	revisions, err := models.ListRevisionByPostID(post.ID)
+	if err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+
+	comments, _ := models.ListUnreadComment(user)
+	data := gin.H{
+		"csrf":      CSRFToken(c),
+		"comments":  comments,
+		"post":      post,
+		"revisions": revisions,
+		"user":      user,
+		"Active":    "posts",
+	}
+	from, to := findRevision(revisions, c.Query("from")), findRevision(revisions, c.Query("to"))
+	if from == nil && to == nil && len(revisions) > 0 {
+		to = revisions[0]
+		if len(revisions) > 1 {
+			from = revisions[1]
+		}
+	}
+	if to != nil {
+		var oldTitle, oldContent string
+		if from != nil {
+			oldTitle, oldContent = from.Title, from.Content
+		}
+		data["from"] = from
+		data["to"] = to
+		data["titleChanged"] = from != nil && oldTitle != to.Title
+		data["diff"] = helpers.DiffLines(oldContent, to.Content)
+	}
+	c.HTML(http.StatusOK, "admin/revision.html", data)
+}
+
+// 恢复文章到指定修订版本
+func PostRevisionRestore(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+	)
+	defer writeJSON(c, res)
+	user := currentUser(c)
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	rid, err := ParamUint(c, "rid")
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	post, err := models.GetAnyPostById(id)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	if !user.CanEditPost(post) {
+		res["message"] = "《" + post.Title + "》只有文章的作者才能恢复修订版本"
+		return
+	}
+	revision, err := models.GetRevisionById(rid)
+	if err != nil || revision.PostID != post.ID {
+		res["message"] = "revision not found"
+		return
+	}
+	if err = post.RestoreRevision(revision, user.ID); err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	res["succeed"] = true
+	// 附件只按文章当前内容计算引用，旧版本引用的附件可能已被清理
+	missing, err := models.MissingAttachmentURLs(post.Content)
+	if err != nil {
+		seelog.Errorf("check attachments of post %d err: %v", post.ID, err)
+		return
+	}
+	if len(missing) > 0 {
+		res["missing_attachments"] = missing
+		res["message"] = "已恢复，以下附件已被清理，需要重新上传：\n" + strings.Join(missing, "\n")
+	}
+}
+
+func findRevision(revisions []*models.PostRevision, value string) *models.PostRevision {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	for _, revision := range revisions {
+		if revision.ID == uint(id) {
+			return revision
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+	"net/http"
+	"strings"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 全文检索页面
+func SearchGet(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+		q         = strings.TrimSpace(c.Query("q"))
+	)
+	results, total, err := searchPost(q, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.SearchPost err: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	data := cachedSidebarData()
+	data["user"] = currentUser(c)
+	data["q"] = q
+	data["results"] = results
+	data["total"] = total
+	data["pageIndex"] = pageIndex
+	data["totalPage"] = totalPage(total, pageSize)
+	c.HTML(http.StatusOK, "index/search.html", data)
+}
+
+// 全文检索 JSON 接口，高亮片段以 <mark> 标签返回
+func SearchJSON(c *gin.Context) {
+	var (
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+		q         = strings.TrimSpace(c.Query("q"))
+	)
+	results, total, err := searchPost(q, pageIndex, pageSize)
+	if err != nil {
+		seelog.Errorf("models.SearchPost err: %v", err)
+		c.JSON(http.StatusInternalServerError, models.BaseResponse{Code: http.StatusInternalServerError, Msg: err.Error()})
+		return
+	}
+	if results == nil {
+		results = []*models.SearchResult{}
+	}
+	for _, result := range results {
+		result.Title = string(helpers.Highlight(result.Title))
+		result.Snippet = string(helpers.Highlight(result.Snippet))
+	}
+	c.JSON(http.StatusOK, models.PageResponse[*models.SearchResult]{
+		BaseResponse: models.BaseResponse{Code: http.StatusOK, Msg: "success"},
+		Payload:      results,
+		Total:        int64(total),
+		Current:      int64(pageIndex),
+		Size:         int64(pageSize),
+	})
+}
+
+func searchPost(q string, pageIndex, pageSize int) (results []*models.SearchResult, total int, err error) {
+	if len(q) == 0 {
+		return
+	}
+	results, err = models.SearchPost(q, pageIndex, pageSize)
+	if err != nil {
+		return
+	}
+	total, err = models.CountSearchPost(q)
+	return
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 钱包登录随机数有效期
+const siweNonceTTL = 10 * time.Minute
+
+// 钱包登录请求体，message 为 EIP-4361 消息，signature 为 personal_sign 签名
+type SiweRequest struct {
+	Message   string `form:"message" json:"message" binding:"required"`
+	Signature string `form:"signature" json:"signature" binding:"required"`
+}
+
+// 每个 IP 每分钟获取随机数的次数限制
+var (
+	siweNonceLimiter     *helpers.RateLimiter
+	siweNonceLimiterOnce sync.Once
+)
+
+// 站点的 scheme 与域名：取自配置中的 domain，未配置或仍为占位符时使用当前请求的 Host
+func siweOrigin(c *gin.Context) (scheme, host string) {
+	domain := system.GetConfiguration().Domain
+	if len(domain) > 0 && !strings.Contains(domain, system.DomainPlaceholder) {
+		if u, err := url.Parse(domain); err == nil && len(u.Host) > 0 {
+			return u.Scheme, u.Host
+		}
+	}
+	scheme = "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme, c.Request.Host
+}
+
+// 消息的校验规则：域名只能为站点域名，签发时间不早于随机数的有效期
+func siweRules(c *gin.Context) helpers.SiweRules {
+	cfg := system.GetConfiguration().Siwe
+	_, host := siweOrigin(c)
+	return helpers.SiweRules{
+		Domain:   host,
+		ChainIDs: cfg.ChainIDs,
+		MaxAge:   siweNonceTTL,
+		Skew:     time.Duration(cfg.ClockSkewSeconds) * time.Second,
+	}
+}
+
+func siweChainAllowed(chainID int64) bool {
+	for _, id := range system.GetConfiguration().Siwe.ChainIDs {
+		if id == chainID {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /siwe/nonce?address=&chain_id= 签发随机数，并按 EIP-4361 生成待签名的消息
+func SiweNonceGet(c *gin.Context) {
+	siweNonceLimiterOnce.Do(func() {
+		siweNonceLimiter = helpers.NewRateLimiter(system.GetConfiguration().Siwe.NonceRateLimit, time.Minute)
+	})
+	if !siweNonceLimiter.Allow(c.ClientIP(), time.Now()) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+		return
+	}
+	chainID, err := strconv.ParseInt(c.DefaultQuery("chain_id", "1"), 10, 64)
+	if err != nil || !siweChainAllowed(chainID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chain id not allowed"})
+		return
+	}
+	nonce, err := models.IssueSiweNonce(siweNonceTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res := gin.H{"nonce": nonce}
+	if address := c.Query("address"); common.IsHexAddress(address) {
+		scheme, host := siweOrigin(c)
+		now := time.Now().UTC().Truncate(time.Second)
+		expires := now.Add(siweNonceTTL)
+		msg := &helpers.SiweMessage{
+			Domain:         host,
+			Address:        common.HexToAddress(address).Hex(),
+			Statement:      "Sign in to " + system.GetConfiguration().Title,
+			URI:            scheme + "://" + host,
+			Version:        "1",
+			ChainID:        chainID,
+			Nonce:          nonce,
+			IssuedAt:       now,
+			ExpirationTime: &expires,
+		}
+		res["message"] = msg.String()
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// 校验消息与签名，并使用随机数
+func verifySiwe(c *gin.Context) (*models.ExternalUser, error) {
+	var param SiweRequest
+	if err := c.ShouldBind(&param); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	msg, err := helpers.ParseSiweMessage(param.Message)
+	if err != nil {
+		return nil, err
+	}
+	if err = msg.Valid(siweRules(c), now); err != nil {
+		return nil, err
+	}
+	address, err := helpers.RecoverPersonalSign(param.Message, param.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if address.Hex() != msg.Address {
+		return nil, helpers.ErrSiweSignatureInvalid
+	}
+	if err = models.ConsumeSiweNonce(msg.Nonce, now); err != nil {
+		return nil, err
+	}
+	return &models.ExternalUser{
+		Provider: models.ProviderEthereum,
+		Subject:  msg.Address,
+		Login:    msg.Address,
+	}, nil
+}
+
+// POST /siwe/verify 钱包登录，首次登录时创建用户并绑定钱包地址
+func SiwePost(c *gin.Context) {
+	ext, err := verifySiwe(c)
+	if err != nil {
+		seelog.Warnf("Sign-in with Ethereum failed from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	user, created, err := models.LoginWithIdentity(ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if user.Locked {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrUserLocked.Error()})
+		return
+	}
+	if created {
+		seelog.Infof("User[ID:%v] registered via wallet %s", user.ID, ext.Subject)
+	}
+
+	// 登录后更换 CSRF token
+	sessions.Default(c).Delete(SessionCSRFKey)
+
+	data, err := issueLoginData(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	saveSessionTokens(c, data)
+
+	c.JSON(http.StatusOK, models.DataResponse[models.LoginData]{
+		BaseResponse: models.BaseResponse{Code: 200, Msg: "success"},
+		Payload:      data,
+	})
+}
+
+// POST /admin/siwe/link 为当前用户绑定钱包地址
+func SiweLink(c *gin.Context) {
+	res := gin.H{}
+	defer writeJSON(c, res)
+	user := currentUser(c)
+	if user == nil {
+		res["message"] = "please login first"
+		return
+	}
+	ext, err := verifySiwe(c)
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	if err = user.LinkIdentity(ext); err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] linked wallet %s", user.ID, ext.Subject)
+	res["succeed"] = true
+	res["address"] = ext.Subject
+}
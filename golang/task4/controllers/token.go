@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"errors"
+	"go-blog/helpers"
+	"go-blog/models"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 刷新令牌请求，未提供时使用 session 中的刷新令牌
+type RefreshRequest struct {
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+}
+
+// 签发访问令牌，与刷新令牌一起返回
+func newLoginData(user *models.User, refreshToken string, refresh *models.RefreshToken) (models.LoginData, error) {
+	exp := time.Now().Add(models.AccessTokenTTL())
+	tokenString, err := helpers.GenerateToken(*user, exp)
+	if err != nil {
+		return models.LoginData{}, err
+	}
+	return models.LoginData{
+		Token:            tokenString,
+		ExpiresAt:        exp.Unix(),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt.Unix(),
+		UserID:           user.ID,
+		Username:         user.Username,
+	}, nil
+}
+
+// 登录成功：签发新的访问令牌与刷新令牌
+func issueLoginData(c *gin.Context, user *models.User) (models.LoginData, error) {
+	refreshToken, refresh, err := models.IssueRefreshToken(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return models.LoginData{}, err
+	}
+	return newLoginData(user, refreshToken, refresh)
+}
+
+// 使用刷新令牌续期，刷新令牌同时轮换
+func rotateLoginData(c *gin.Context, refreshToken string) (models.LoginData, error) {
+	refreshToken, refresh, err := models.RotateRefreshToken(refreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return models.LoginData{}, err
+	}
+	user, err := models.GetUser(refresh.UserID)
+	if err != nil {
+		return models.LoginData{}, models.ErrRefreshTokenInvalid
+	}
+	if user.Locked {
+		return models.LoginData{}, models.ErrUserLocked
+	}
+	return newLoginData(user, refreshToken, refresh)
+}
+
+// 同一 session 的并发请求携带相同的刷新令牌，轮换结果在宽限期内共享，避免被误判为重复使用
+const sessionRotateGrace = 10 * time.Second
+
+type sessionRotation struct {
+	once sync.Once
+	data models.LoginData
+	err  error
+}
+
+// 按刷新令牌记录进行中与刚完成的轮换
+var sessionRotations sync.Map
+
+// 轮换 session 中的刷新令牌，同一令牌只轮换一次，宽限期内的其他请求等待并返回同一个新令牌
+func rotateSessionData(c *gin.Context, refreshToken string) (models.LoginData, error) {
+	if len(refreshToken) == 0 {
+		return models.LoginData{}, models.ErrRefreshTokenInvalid
+	}
+	v, _ := sessionRotations.LoadOrStore(refreshToken, &sessionRotation{})
+	rotation := v.(*sessionRotation)
+	rotation.once.Do(func() {
+		rotation.data, rotation.err = rotateLoginData(c, refreshToken)
+		time.AfterFunc(sessionRotateGrace, func() { sessionRotations.Delete(refreshToken) })
+	})
+	return rotation.data, rotation.err
+}
+
+// 将令牌写入 session，供页面访问使用
+func saveSessionTokens(c *gin.Context, data models.LoginData) {
+	session := sessions.Default(c)
+	session.Set(SessionJwtKey, data.Token)
+	session.Set(SessionRefreshKey, data.RefreshToken)
+	session.Set("ExpiresAt", data.ExpiresAt)
+	session.Set(SessionKey, data.UserID)
+	session.Set("Username", data.Username)
+	if err := session.Save(); err != nil {
+		seelog.Error("saveSessionTokens session.Save Error: " + err.Error())
+	}
+}
+
+// 清除 session 中的登录信息
+func clearSession(c *gin.Context) {
+	s := sessions.Default(c)
+	s.Clear()
+	if err := s.Save(); err != nil {
+		seelog.Error(err)
+	}
+}
+
+// RefreshSession 访问令牌过期时，使用 session 中的刷新令牌续期
+func RefreshSession(c *gin.Context) (*helpers.MyClaims, error) {
+	refreshToken, _ := sessions.Default(c).Get(SessionRefreshKey).(string)
+	if len(refreshToken) == 0 {
+		return nil, models.ErrRefreshTokenInvalid
+	}
+	data, err := rotateSessionData(c, refreshToken)
+	if err != nil {
+		clearSession(c)
+		return nil, err
+	}
+	saveSessionTokens(c, data)
+	return helpers.ParseToken(data.Token)
+}
+
+// 使用刷新令牌换取新的访问令牌与刷新令牌
+func TokenRefresh(c *gin.Context) {
+	var param RefreshRequest
+	if err := c.ShouldBind(&param); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fromSession := len(param.RefreshToken) == 0
+	if fromSession {
+		param.RefreshToken, _ = sessions.Default(c).Get(SessionRefreshKey).(string)
+	}
+	var (
+		data models.LoginData
+		err  error
+	)
+	if fromSession {
+		data, err = rotateSessionData(c, param.RefreshToken)
+	} else {
+		data, err = rotateLoginData(c, param.RefreshToken)
+	}
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, models.ErrUserLocked) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if fromSession {
+		saveSessionTokens(c, data)
+	}
+	c.JSON(http.StatusOK, models.DataResponse[models.LoginData]{
+		BaseResponse: models.BaseResponse{Code: 200, Msg: "success"},
+		Payload:      data,
+	})
+}
+
+// 退出所有设备：吊销当前用户的全部令牌
+func LogoutAllPost(c *gin.Context) {
+	user := currentUser(c)
+	if err := user.LogoutEverywhere(); err != nil {
+		HandleMessage(c, err.Error())
+		return
+	}
+	seelog.Infof("User[ID:%v] logged out everywhere", user.ID)
+	clearSession(c)
+	c.Redirect(http.StatusSeeOther, "/signin")
+}
+
+// POST /api/v1/logout 吊销当前访问令牌，请求体中提供 refresh_token 时一并吊销
+func APILogout(c *gin.Context) {
+	var param RefreshRequest
+	_ = c.ShouldBind(&param)
+	if claims, ok := c.Get(ContextClaimsKey); ok {
+		if err := helpers.RevokeClaims(claims.(*helpers.MyClaims)); err != nil {
+			apiError(c, CodeServerError, err.Error())
+			return
+		}
+	}
+	if len(param.RefreshToken) > 0 {
+		if err := models.RevokeRefreshToken(param.RefreshToken); err != nil {
+			apiError(c, CodeServerError, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, models.BaseResponse{Code: CodeSuccess, Msg: "success"})
+}
+
+// POST /api/v1/logout_all 退出所有设备
+func APILogoutAll(c *gin.Context) {
+	user := currentUser(c)
+	if err := user.LogoutEverywhere(); err != nil {
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	seelog.Infof("User[ID:%v] logged out everywhere", user.ID)
+	c.JSON(http.StatusOK, models.BaseResponse{Code: CodeSuccess, Msg: "success"})
+}
+
+// GET /.well-known/jwks.json 公钥集合，供其他服务验证 token
+func JWKSGet(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, helpers.JWKS())
+}
@@ -1,7 +1,162 @@
 package controllers
 
-import "mime/multipart"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-blog/system"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
 
+	"github.com/pkg/errors"
+)
+
+// Uploader 附件存储后端，由配置文件中的 file_server 选择
 type Uploader interface {
-	upload(file multipart.File, fileHeader *multipart.FileHeader) (string, error)
+	// Put 保存内容并返回访问地址；key 由内容哈希生成，已存在时不重复写入
+	Put(key, contentType string, data []byte) (string, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+}
+
+var ErrFileTooLarge = errors.New("file too large")
+
+// 替换后的存储后端，为空时按配置创建
+var uploaderOverride Uploader
+
+// SetUploader 替换存储后端（如测试中使用 MemoryUploader），传入 nil 时恢复按配置创建
+func SetUploader(u Uploader) {
+	uploaderOverride = u
+}
+
+// 按配置创建存储后端
+func newUploader(cfg *system.Configuration) (Uploader, error) {
+	if uploaderOverride != nil {
+		return uploaderOverride, nil
+	}
+	switch cfg.FileServer {
+	case "local", "":
+		return LocalUploader{BasePath: cfg.Upload.LocalPath, BaseURL: cfg.Upload.LocalURL}, nil
+	case "s3":
+		return NewS3Uploader(cfg.Upload.S3)
+	}
+	return nil, fmt.Errorf("file_server %q not supported, use local or s3", cfg.FileServer)
+}
+
+// MIME 类型匹配
+func mimeMatch(mime string, allowed []string) bool {
+	for _, t := range allowed {
+		if strings.HasSuffix(t, "/*") {
+			prefix := strings.TrimSuffix(t, "*")
+			if strings.HasPrefix(mime, prefix) {
+				return true
+			}
+		} else if mime == t {
+			return true
+		}
+	}
+	return false
+}
+
+// 常见类型使用固定的扩展名，保证同一内容得到同一个文件名
+var mimeExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+}
+
+func extensionByType(contentType string) string {
+	if ext, ok := mimeExtensions[contentType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// StoredFile 上传结果，图片附带尺寸与缩放后的版本
+type StoredFile struct {
+	Key         string          `json:"key"`
+	URL         string          `json:"url"`
+	ContentType string          `json:"content_type"`
+	Size        int64           `json:"size"`
+	Hash        string          `json:"hash"` // 保存内容的 SHA-256
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	Variants    []StoredVariant `json:"variants,omitempty"`
+}
+
+type StoredVariant struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Srcset 生成 <img srcset> 属性值，包含各缩放版本与原图
+func (f *StoredFile) Srcset() string {
+	if f.Width == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(f.Variants)+1)
+	for _, v := range f.Variants {
+		items = append(items, fmt.Sprintf("%s %dw", v.URL, v.Width))
+	}
+	items = append(items, fmt.Sprintf("%s %dw", f.URL, f.Width))
+	return strings.Join(items, ", ")
+}
+
+// StoreFile 校验文件大小与类型，处理图片后以内容的 SHA-256 命名写入存储后端，重复上传的文件只保存一份
+func StoreFile(uploader Uploader, r io.Reader, upload system.Upload) (*StoredFile, error) {
+	if upload.MaxSize > 0 {
+		r = io.LimitReader(r, upload.MaxSize+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if upload.MaxSize > 0 && int64(len(data)) > upload.MaxSize {
+		return nil, ErrFileTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i > 0 {
+		contentType = contentType[:i]
+	}
+	if len(upload.FileTypes) > 0 && !mimeMatch(contentType, upload.FileTypes) {
+		return nil, fmt.Errorf("不允许上传该类型文件: %s", contentType)
+	}
+
+	var img *uploadImage
+	if strings.HasPrefix(contentType, "image/") {
+		if img, err = processImage(data, contentType, upload); err != nil {
+			return nil, err
+		}
+		data = img.data
+	}
+
+	sum := sha256.Sum256(data)
+	file := &StoredFile{
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Hash:        hex.EncodeToString(sum[:]),
+	}
+	file.Key = file.Hash + extensionByType(contentType)
+	if file.URL, err = uploader.Put(file.Key, contentType, data); err != nil {
+		return nil, err
+	}
+	if img != nil {
+		file.Width, file.Height = img.width, img.height
+		if file.Variants, err = storeVariants(uploader, file.Hash, img, upload); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
 }
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"go-blog/helpers"
+	"go-blog/system"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// 已校验的上传图片
+type uploadImage struct {
+	data          []byte // 去除元数据后保存的内容
+	contentType   string
+	width, height int
+	orientation   int
+	decoded       image.Image
+}
+
+// 校验图片尺寸并去除元数据；无法解码的格式（如 svg、tiff）无法校验，直接拒绝
+func processImage(data []byte, contentType string, upload system.Upload) (*uploadImage, error) {
+	// 只读取文件头中的尺寸，避免解码超大图片
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, fmt.Errorf("不支持的图片格式: %s", contentType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("图片格式错误: %v", err)
+	}
+	if (upload.MaxWidth > 0 && conf.Width > upload.MaxWidth) || (upload.MaxHeight > 0 && conf.Height > upload.MaxHeight) {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超过限制 %dx%d", conf.Width, conf.Height, upload.MaxWidth, upload.MaxHeight)
+	}
+	img := &uploadImage{data: data, contentType: contentType, width: conf.Width, height: conf.Height, orientation: 1}
+	if contentType == "image/jpeg" {
+		img.orientation = helpers.JPEGOrientation(data)
+		if img.orientation >= 5 {
+			img.width, img.height = img.height, img.width
+		}
+	}
+	if !upload.StripMetadata {
+		return img, nil
+	}
+	switch contentType {
+	case "image/jpeg":
+		// 去除 EXIF 后方向信息丢失，需要先按方向旋转再重新编码
+		if img.orientation > 1 {
+			decoded, err := img.decode()
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if err = jpeg.Encode(&buf, decoded, &jpeg.Options{Quality: jpegQuality(upload)}); err != nil {
+				return nil, err
+			}
+			img.data = buf.Bytes()
+			return img, nil
+		}
+		img.data, err = helpers.StripJPEGMetadata(data)
+	case "image/png":
+		img.data, err = helpers.StripPNGMetadata(data)
+	case "image/webp":
+		img.data, err = helpers.StripWebPMetadata(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("图片格式错误: %v", err)
+	}
+	return img, nil
+}
+
+// 解码并按 EXIF 方向摆正
+func (img *uploadImage) decode() (image.Image, error) {
+	if img.decoded == nil {
+		decoded, _, err := image.Decode(bytes.NewReader(img.data))
+		if err != nil {
+			return nil, fmt.Errorf("图片格式错误: %v", err)
+		}
+		img.decoded = helpers.OrientImage(decoded, img.orientation)
+		img.orientation = 1
+	}
+	return img.decoded, nil
+}
+
+func jpegQuality(upload system.Upload) int {
+	if upload.JPEGQuality < 1 || upload.JPEGQuality > 100 {
+		return jpeg.DefaultQuality
+	}
+	return upload.JPEGQuality
+}
+
+// 生成并保存缩放版本，文件名为 {原图哈希}-{名称}{扩展名}
+func storeVariants(uploader Uploader, hash string, img *uploadImage, upload system.Upload) ([]StoredVariant, error) {
+	var variants []StoredVariant
+	for _, v := range upload.Variants {
+		if v.Width <= 0 || v.Width >= img.width {
+			continue
+		}
+		decoded, err := img.decode()
+		if err != nil {
+			return nil, err
+		}
+		resized := helpers.ResizeImage(decoded, v.Width)
+		// JPEG 保持 JPEG，其余格式（GIF 取第一帧）统一保存为 PNG
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if img.contentType == "image/jpeg" {
+			contentType = "image/jpeg"
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: jpegQuality(upload)})
+		} else {
+			err = png.Encode(&buf, resized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := hash + "-" + v.Name + extensionByType(contentType)
+		url, err := uploader.Put(key, contentType, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		b := resized.Bounds()
+		variants = append(variants, StoredVariant{Name: v.Name, Key: key, URL: url, Width: b.Dx(), Height: b.Dy()})
+	}
+	return variants, nil
+}
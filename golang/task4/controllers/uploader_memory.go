@@ -0,0 +1,45 @@
+package controllers
+
+import "sync"
+
+// MemoryUploader 保存在内存中，没有对应的访问路由，只能在测试中通过 SetUploader 使用
+type MemoryUploader struct {
+	BaseURL string
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryUploader(baseURL string) *MemoryUploader {
+	return &MemoryUploader{BaseURL: baseURL, objects: map[string][]byte{}}
+}
+
+func (u *MemoryUploader) Put(key, _ string, data []byte) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.objects[key]; !ok {
+		u.objects[key] = append([]byte(nil), data...)
+	}
+	return u.BaseURL + key, nil
+}
+
+func (u *MemoryUploader) Delete(key string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.objects, key)
+	return nil
+}
+
+// Get 读取已保存的内容
+func (u *MemoryUploader) Get(key string) ([]byte, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	data, ok := u.objects[key]
+	return data, ok
+}
+
+// Len 已保存的文件数量
+func (u *MemoryUploader) Len() int {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return len(u.objects)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-blog/system"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Uploader 保存到 S3 兼容的对象存储，请求使用 AWS Signature Version 4 签名
+type S3Uploader struct {
+	system.S3
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3Uploader(conf system.S3) (*S3Uploader, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(conf.Endpoint, "/"))
+	if err != nil || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", conf.Endpoint)
+	}
+	if len(conf.Bucket) == 0 {
+		return nil, fmt.Errorf("s3 bucket not configured")
+	}
+	return &S3Uploader{S3: conf, endpoint: endpoint, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// 对象地址，path_style 时为 {endpoint}/{bucket}/{key}，否则为 {bucket}.{host}/{key}
+func (u *S3Uploader) objectURL(key string) string {
+	if u.PathStyle {
+		return fmt.Sprintf("%s://%s%s/%s/%s", u.endpoint.Scheme, u.endpoint.Host, u.endpoint.Path, u.Bucket, key)
+	}
+	return fmt.Sprintf("%s://%s.%s%s/%s", u.endpoint.Scheme, u.Bucket, u.endpoint.Host, u.endpoint.Path, key)
+}
+
+func (u *S3Uploader) publicURL(key string) string {
+	if len(u.PublicURL) > 0 {
+		return strings.TrimSuffix(u.PublicURL, "/") + "/" + key
+	}
+	return u.objectURL(key)
+}
+
+func (u *S3Uploader) Put(key, contentType string, data []byte) (string, error) {
+	// 对象已存在说明内容相同，无需重复上传
+	resp, err := u.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return u.publicURL(key), nil
+	case http.StatusNotFound:
+	default:
+		return "", fmt.Errorf("s3 head %s: %s", key, resp.Status)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	// 文件名由内容决定，可以长期缓存
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	resp, err = u.do(http.MethodPut, key, header, data)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("s3 put %s: %s %s", key, resp.Status, body)
+	}
+	return u.publicURL(key), nil
+}
+
+func (u *S3Uploader) Delete(key string) error {
+	resp, err := u.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 delete %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (u *S3Uploader) do(method, key string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	u.sign(req, body, time.Now().UTC())
+	return u.client.Do(req)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// 按 AWS Signature Version 4 为请求签名，见
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+func (u *S3Uploader) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if name == "content-type" || name == "cache-control" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + u.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+u.SecretKey), date)
+	key = hmacSHA256(key, u.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.AccessKey, scope, signedHeaders, signature))
+}
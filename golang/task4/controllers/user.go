@@ -1,51 +1,46 @@
 package controllers
 
 import (
+	"fmt"
 	"go-blog/helpers"
 	"go-blog/models"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/cihub/seelog"
+	"github.com/dchest/captcha"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type GithubUserInfo struct {
-	Blog      string      `json:"blog"`
-	CreatedAt string      `json:"created_at"`
-	Email     interface{} `json:"email"`
-	HTMLURL   string      `json:"html_url"`
-	ID        int         `json:"id"`
-	Login     string      `json:"login"`
-	Name      interface{} `json:"name"`
-	UpdatedAt string      `json:"updated_at"`
-	URL       string      `json:"url"`
-}
-
 func SigninGet(c *gin.Context) {
 	c.HTML(http.StatusOK, "auth/signin.html", gin.H{
-		"cfg": "",
+		"csrf": CSRFToken(c),
+		"cfg":  "",
 	})
 }
 
 func SignupGet(c *gin.Context) {
 	c.HTML(http.StatusOK, "auth/signup.html", gin.H{
-		"cfg": "",
+		"csrf": CSRFToken(c),
+		"cfg":  "",
 	})
 }
 
 func LogoutGet(c *gin.Context) {
 	s := sessions.Default(c)
-	s.Delete(SessionJwtKey)
-	s.Delete(ContextUserKey)
-	s.Delete(SessionKey)
-	s.Clear()
-	err := s.Save()
-	if err != nil {
-		seelog.Error(err)
+	// 吊销 session 中的访问令牌与刷新令牌
+	if tokenString, _ := s.Get(SessionJwtKey).(string); len(tokenString) > 0 {
+		if claims, err := helpers.ParseToken(tokenString); err == nil {
+			_ = helpers.RevokeClaims(claims)
+		}
+	}
+	if refreshToken, _ := s.Get(SessionRefreshKey).(string); len(refreshToken) > 0 {
+		_ = models.RevokeRefreshToken(refreshToken)
 	}
+	clearSession(c)
 	c.Redirect(http.StatusSeeOther, "/signin")
 }
 
@@ -59,6 +54,7 @@ func SignupPost(c *gin.Context) {
 	if err = c.ShouldBind(&param); err != nil {
 		seelog.Infof(err.Error())
 		c.HTML(http.StatusOK, "auth/signup.html", gin.H{
+			"csrf":    CSRFToken(c),
 			"message": "param invalid",
 		})
 		return
@@ -66,15 +62,26 @@ func SignupPost(c *gin.Context) {
 
 	if len(param.Password) == 0 || len(param.Username) == 0 {
 		c.HTML(http.StatusOK, "auth/signup.html", gin.H{
+			"csrf":    CSRFToken(c),
 			"message": "email or password cannot be null",
 		})
 		return
 	}
 
+	param.Email = strings.TrimSpace(param.Email)
+	if !validEmail(param.Email) {
+		c.HTML(http.StatusOK, "auth/signup.html", gin.H{
+			"csrf":    CSRFToken(c),
+			"message": "email invalid",
+		})
+		return
+	}
+
 	// 校验密码强度
 	err = helpers.ValidatePasswordStrength(param.Password)
 	if err != nil {
 		c.HTML(http.StatusOK, "auth/signup.html", gin.H{
+			"csrf":    CSRFToken(c),
 			"message": "password is simple",
 		})
 		return
@@ -87,10 +94,6 @@ func SignupPost(c *gin.Context) {
 		return
 	}
 
-	// 随机设置一个邮箱号
-	email, _ := helpers.RandomEmail()
-	param.Email = email
-
 	user := &models.User{
 		Email:    param.Email,
 		Username: param.Username,
@@ -99,22 +102,24 @@ func SignupPost(c *gin.Context) {
 	err = user.Insert()
 	if err != nil {
 		c.HTML(http.StatusOK, "auth/signup.html", gin.H{
+			"csrf":    CSRFToken(c),
 			"message": "register info already exists",
 			"cfg":     "",
 		})
 		return
 	}
+	sendVerifyEmail(user)
+	helpers.NotifyAdmins("New user registered", fmt.Sprintf("User %s <%s> registered from %s.\n", user.Username, user.Email, c.ClientIP()))
 	c.Redirect(http.StatusMovedPermanently, "/signin")
 }
 
 // 用户登陆接口
 func SigninPost(c *gin.Context) {
 	var (
-		err         error
-		param       *models.LoginRequest
-		user        *models.User
-		tokenString string
-		errTip      = "Invalid username or password"
+		err    error
+		param  *models.LoginRequest
+		user   *models.User
+		errTip = "Invalid username or password"
 	)
 
 	if err := c.ShouldBind(&param); err != nil {
@@ -122,45 +127,149 @@ func SigninPost(c *gin.Context) {
 		return
 	}
 
+	// 暴力破解保护：用户名或 IP 被锁定时拒绝登录，连续失败后要求验证码
+	now := time.Now()
+	status := models.CheckLogin(param.Username, c.ClientIP(), now)
+	if status.LockedUntil != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":            "Too many failed attempts, try again after " + status.LockedUntil.Format("2006-01-02 15:04:05"),
+			"locked_until":     status.LockedUntil.Unix(),
+			"captcha_required": true,
+		})
+		return
+	}
+	if status.CaptchaRequired && !captcha.VerifyString(param.CaptchaId, param.VerifyCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Error verification code", "captcha_required": true})
+		return
+	}
+
 	user, err = models.GetUserByUsername(param.Username)
+	if err == nil {
+		// 验证密码
+		err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(param.Password))
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": errTip})
+		if status, err = models.RecordLoginFailure(param.Username, c.ClientIP(), now); err != nil {
+			seelog.Errorf("Record signin failure for %s from %s err: %v", param.Username, c.ClientIP(), err)
+		}
+		seelog.Warnf("Signin failed for %s from %s", param.Username, c.ClientIP())
+		if status.LockedUntil != nil {
+			helpers.NotifyAdmins("Sign-in locked", fmt.Sprintf("Sign-in for %s from %s is locked until %s after repeated failures.\n",
+				param.Username, c.ClientIP(), status.LockedUntil.Format("2006-01-02 15:04:05")))
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errTip, "captcha_required": status.CaptchaRequired})
 		return
 	}
+	_ = models.ResetLoginFailure(param.Username)
 
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(param.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": errTip})
+	// 锁定的用户禁止登录
+	if user.Locked {
+		c.JSON(http.StatusForbidden, gin.H{"error": models.ErrUserLocked.Error()})
 		return
 	}
 
-	// 生成 JWT
-	exp := time.Now().Add(time.Hour * 24)
-	tokenString, err = helpers.GenerateToken(*user, exp)
+	// 登录后更换 CSRF token
+	sessions.Default(c).Delete(SessionCSRFKey)
+
+	// 签发访问令牌与刷新令牌
+	data, err := issueLoginData(c, user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	saveSessionTokens(c, data)
 
-	resp := models.DataResponse[models.LoginData]{
+	c.JSON(http.StatusOK, models.DataResponse[models.LoginData]{
 		BaseResponse: models.BaseResponse{Code: 200, Msg: "success"},
-		Payload: models.LoginData{
-			Token:     tokenString,
-			ExpiresAt: exp.Unix(),
-			UserID:    user.ID,
-			Username:  user.Username,
-		},
-	}
-
-	session := sessions.Default(c)
-	session.Set("Token", tokenString)
-	session.Set("ExpiresAt", exp.Unix())
-	session.Set("UserID", user.ID)
-	session.Set("Username", user.Username)
-	err = session.Save()
+		Payload:      data,
+	})
+}
+
+// 用户管理列表，支持按用户名筛选
+func UserIndex(c *gin.Context) {
+	var (
+		query     models.UserQuery
+		pageIndex = queryPageIndex(c)
+		pageSize  = configPageSize()
+	)
+	_ = c.ShouldBindQuery(&query)
+	user := currentUser(c)
+	users, err := models.ListUser(query, pageIndex, pageSize)
 	if err != nil {
-		seelog.Error("SigninPost session.Save Error: " + err.Error())
+		HandleMessage(c, err.Error())
+		return
 	}
+	total, _ := models.CountUser(query)
+	comments, _ := models.ListUnreadComment(user)
+	c.HTML(http.StatusOK, "admin/user.html", gin.H{
+		"csrf":      CSRFToken(c),
+		"users":     users,
+		"roles":     models.Roles,
+		"query":     query,
+		"comments":  comments,
+		"user":      user,
+		"Active":    "users",
+		"pageIndex": pageIndex,
+		"totalPage": totalPage(int(total), pageSize),
+		"path":      c.Request.URL.Path,
+	})
+}
+
+// 分配用户角色，表单参数 role
+func UserRole(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+	)
+	defer writeJSON(c, res)
+	target, ok := loadManagedUser(c, res)
+	if !ok {
+		return
+	}
+	err = target.UpdateRole(c.PostForm("role"))
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] set role of User[ID:%v] to %s", currentUser(c).ID, target.ID, target.Role)
+	res["succeed"] = true
+}
+
+// 锁定、解锁用户，表单参数 locked 为 true 时锁定
+func UserLock(c *gin.Context) {
+	var (
+		err error
+		res = gin.H{}
+	)
+	defer writeJSON(c, res)
+	target, ok := loadManagedUser(c, res)
+	if !ok {
+		return
+	}
+	err = target.UpdateLocked(c.PostForm("locked") == "true")
+	if err != nil {
+		res["message"] = err.Error()
+		return
+	}
+	seelog.Infof("User[ID:%v] set locked of User[ID:%v] to %v", currentUser(c).ID, target.ID, target.Locked)
+	res["succeed"] = true
+}
 
-	c.JSON(http.StatusOK, resp)
+// 读取被管理的用户，不允许修改自己的角色与锁定状态
+func loadManagedUser(c *gin.Context, res gin.H) (*models.User, bool) {
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		res["message"] = err.Error()
+		return nil, false
+	}
+	if id == currentUser(c).ID {
+		res["message"] = models.ErrModifySelf.Error()
+		return nil, false
+	}
+	target, err := models.GetUser(id)
+	if err != nil {
+		res["message"] = err.Error()
+		return nil, false
+	}
+	return target, true
 }
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"fmt"
+	"go-blog/helpers"
+	"go-blog/jobs"
+	"go-blog/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/gin-gonic/gin"
+)
+
+// 阅读排行默认统计的天数
+const (
+	mostReadDays = 30
+	trendingDays = 7
+)
+
+// 访客标识：登录用户为用户 ID，否则为 IP 与 User-Agent 的摘要
+func visitorKey(c *gin.Context) string {
+	if user := currentUser(c); user != nil {
+		return fmt.Sprintf("u%d", user.ID)
+	}
+	return "v" + helpers.Md5(c.ClientIP()+"|"+c.Request.UserAgent())
+}
+
+// CountView 文章页成功返回（包括 304）后记录阅读数，忽略爬虫；需放在文章页处理函数之前，缓存命中时同样计数
+func CountView(c *gin.Context) {
+	c.Next()
+	if status := c.Writer.Status(); status != http.StatusOK && status != http.StatusNotModified {
+		return
+	}
+	if helpers.IsBot(c.Request.UserAgent()) {
+		return
+	}
+	id, err := ParamUint(c, "id")
+	if err != nil {
+		return
+	}
+	jobs.RecordView(id, visitorKey(c), time.Now())
+}
+
+// 统计天数，取自 ?days=，0 为全部时间
+func queryDays(c *gin.Context, def int) int {
+	if days, err := strconv.Atoi(c.Query("days")); err == nil && days >= 0 && days <= 365 {
+		return days
+	}
+	return def
+}
+
+func apiRankedPosts(c *gin.Context, list func(days, limit int) ([]*models.Post, error), def int) {
+	_, size := apiPageParams(c)
+	posts, err := list(queryDays(c, def), size)
+	if err != nil {
+		seelog.Errorf("list ranked posts err: %v", err)
+		apiError(c, CodeServerError, err.Error())
+		return
+	}
+	payload := make([]gin.H, 0, len(posts))
+	for _, post := range posts {
+		payload = append(payload, gin.H{
+			"post":  models.NewPostData(post),
+			"views": post.ViewTotal,
+		})
+	}
+	apiData(c, CodeSuccess, payload)
+}
+
+// GET /api/v1/posts/most_read?days=30&size=10 最近 days 天阅读最多的文章，days=0 按总阅读数
+func APIPostMostRead(c *gin.Context) {
+	apiRankedPosts(c, models.ListMostReadPost, mostReadDays)
+}
+
+// GET /api/v1/posts/trending?days=7&size=10 近期热门文章，越新的阅读权重越高
+func APIPostTrending(c *gin.Context) {
+	apiRankedPosts(c, models.ListTrendingPost, trendingDays)
+}
@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// 行级差异类型
+const (
+	DiffEqual  = " "
+	DiffInsert = "+"
+	DiffDelete = "-"
+)
+
+// DiffLine 差异结果中的一行，OldLine/NewLine 为行号，不存在时为 0
+type DiffLine struct {
+	Op      string
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// DiffLines 基于最长公共子序列计算两段文本的行级差异，使用 Hirschberg 算法，内存占用与行数成线性关系
+func DiffLines(oldText, newText string) []DiffLine {
+	a, b := splitLines(oldText), splitLines(newText)
+	return diffRange(make([]DiffLine, 0, max(len(a), len(b))), a, b, 0, 0)
+}
+
+// 计算 a、b 之间的差异并追加到 lines，i0、j0 为 a、b 首行在原文中的下标
+func diffRange(lines []DiffLine, a, b []string, i0, j0 int) []DiffLine {
+	// 公共前缀
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[0], OldLine: i0 + 1, NewLine: j0 + 1})
+		a, b = a[1:], b[1:]
+		i0++
+		j0++
+	}
+	// 公共后缀，最后追加
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	suffix := a[len(a)-n:]
+	a, b = a[:len(a)-n], b[:len(b)-n]
+
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		lines = appendDeletes(lines, a, i0)
+		lines = appendInserts(lines, b, j0)
+	case len(a) == 1:
+		k := 0
+		for k < len(b) && b[k] != a[0] {
+			k++
+		}
+		if k == len(b) {
+			lines = appendDeletes(lines, a, i0)
+			lines = appendInserts(lines, b, j0)
+			break
+		}
+		lines = appendInserts(lines, b[:k], j0)
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[0], OldLine: i0 + 1, NewLine: j0 + k + 1})
+		lines = appendInserts(lines, b[k+1:], j0+k+1)
+	default:
+		// 在 a 的中间切分，前后两半与 b 的 LCS 长度之和最大处即为 b 的切分点
+		mid := len(a) / 2
+		front := lcsRow(a[:mid], b)
+		back := lcsRow(reversed(a[mid:]), reversed(b))
+		k := 0
+		for j := range front {
+			if front[j]+back[len(b)-j] > front[k]+back[len(b)-k] {
+				k = j
+			}
+		}
+		lines = diffRange(lines, a[:mid], b[:k], i0, j0)
+		lines = diffRange(lines, a[mid:], b[k:], i0+mid, j0+k)
+	}
+
+	i0, j0 = i0+len(a), j0+len(b)
+	for k, text := range suffix {
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: text, OldLine: i0 + k + 1, NewLine: j0 + k + 1})
+	}
+	return lines
+}
+
+// 返回 row，row[j] 为 a 与 b[:j] 的最长公共子序列长度，只保留两行
+func lcsRow(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for _, x := range a {
+		for j, y := range b {
+			if x == y {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func reversed(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[len(lines)-1-i] = line
+	}
+	return out
+}
+
+func appendDeletes(lines []DiffLine, a []string, i0 int) []DiffLine {
+	for k, text := range a {
+		lines = append(lines, DiffLine{Op: DiffDelete, Text: text, OldLine: i0 + k + 1})
+	}
+	return lines
+}
+
+func appendInserts(lines []DiffLine, b []string, j0 int) []DiffLine {
+	for k, text := range b {
+		lines = append(lines, DiffLine{Op: DiffInsert, Text: text, NewLine: j0 + k + 1})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,255 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+
+	"github.com/pkg/errors"
+)
+
+var ErrImageInvalid = errors.New("image invalid")
+
+// StripJPEGMetadata 去除 JPEG 中的 EXIF/XMP（APP1）、IPTC（APP13）与注释段，图像数据保持不变
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrImageInvalid
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	for i := 2; i < len(data); {
+		if data[i] != 0xFF || i+1 >= len(data) {
+			return nil, ErrImageInvalid
+		}
+		marker := data[i+1]
+		// 填充字节
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		// SOS 之后为压缩数据，原样保留
+		if marker == 0xDA {
+			out.Write(data[i:])
+			break
+		}
+		if i+4 > len(data) {
+			return nil, ErrImageInvalid
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return nil, ErrImageInvalid
+		}
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// StripPNGMetadata 去除 PNG 中的 eXIf、文本与时间块
+func StripPNGMetadata(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, ErrImageInvalid
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(signature)
+	for i := len(signature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrImageInvalid
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, ErrImageInvalid
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "iTXt", "zTXt", "tIME":
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// StripWebPMetadata 去除 WebP 中的 EXIF 与 XMP 块，并清除 VP8X 中对应的标志位
+func StripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrImageInvalid
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrImageInvalid
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// 块的长度为奇数时补一个字节
+		end := i + 8 + size + size&1
+		if end > len(data) || end < i {
+			return nil, ErrImageInvalid
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
+
+// JPEGOrientation 读取 EXIF 中的方向（1-8），没有时返回 1
+func JPEGOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA {
+			break
+		}
+		// 段长度包含自身的两个字节，小于 2 即为损坏的数据
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		if segment := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// 在 TIFF 的 IFD0 中查找 Orientation（0x0112）
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for j := 0; j < count; j++ {
+		entry := offset + 2 + j*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// 转为 RGBA 以便直接读写像素
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// OrientImage 按 EXIF 方向旋转、翻转图片，使其正向显示
+func OrientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// ResizeImage 按宽度等比缩小图片，每个像素取原图对应区域的平均值
+func ResizeImage(img image.Image, width int) image.Image {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if width <= 0 || width >= w {
+		return src
+	}
+	height := (h*width + w/2) / w
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0, sy1 := y*h/height, (y+1)*h/height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0, sx1 := x*w/width, (x+1)*w/width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var sum [4]int
+			for sy := sy0; sy < sy1; sy++ {
+				p := src.Pix[src.PixOffset(sx0, sy) : src.PixOffset(sx1-1, sy)+4]
+				for k := 0; k < len(p); k += 4 {
+					sum[0] += int(p[k])
+					sum[1] += int(p[k+1])
+					sum[2] += int(p[k+2])
+					sum[3] += int(p[k+3])
+				}
+			}
+			n := (sy1 - sy0) * (sx1 - sx0)
+			d := dst.Pix[dst.PixOffset(x, y):]
+			for k := 0; k < 4; k++ {
+				d[k] = uint8((sum[k] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
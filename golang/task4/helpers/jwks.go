@@ -0,0 +1,206 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"go-blog/system"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// 非对称签名密钥
+type JWTKey struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey // 仅签名密钥需要
+	Public  crypto.PublicKey
+}
+
+// 已加载的密钥，按 kid 索引；为空时使用 HS256 共享密钥
+var (
+	jwtKeys    = map[string]*JWTKey{}
+	signingKey *JWTKey
+)
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case "RS256":
+		return jwt.SigningMethodRS256, nil
+	case "EdDSA":
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("jwt algorithm %q not supported, use RS256 or EdDSA", algorithm)
+}
+
+func loadJWTKey(cfg system.JWTKey) (*JWTKey, error) {
+	method, err := signingMethod(cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	key := &JWTKey{Kid: cfg.Kid, Method: method}
+	if len(cfg.PrivateKey) > 0 {
+		data, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		if method == jwt.SigningMethodRS256 {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.Private, key.Public = private, private.Public()
+		} else {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.Private, key.Public = private, private.(ed25519.PrivateKey).Public()
+		}
+	}
+	if len(cfg.PublicKey) > 0 {
+		data, err := os.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if method == jwt.SigningMethodRS256 {
+			key.Public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		} else {
+			key.Public, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if key.Public == nil {
+		return nil, errors.New("private_key or public_key is required")
+	}
+	return key, nil
+}
+
+// LoadJWTKeys 加载配置文件中 [[jwt.keys]] 的密钥，signing_kid 指定的密钥用于签名，
+// 其余密钥只用于验证，轮换期间新旧密钥签发的 token 同时有效
+func LoadJWTKeys(cfg system.JWT) error {
+	keys := make(map[string]*JWTKey, len(cfg.Keys))
+	for _, item := range cfg.Keys {
+		if len(item.Kid) == 0 {
+			return errors.New("jwt key kid is required")
+		}
+		if _, ok := keys[item.Kid]; ok {
+			return fmt.Errorf("jwt key %s duplicated", item.Kid)
+		}
+		key, err := loadJWTKey(item)
+		if err != nil {
+			return errors.Wrapf(err, "load jwt key %s", item.Kid)
+		}
+		keys[item.Kid] = key
+	}
+	var signing *JWTKey
+	if len(cfg.SigningKid) > 0 {
+		signing = keys[cfg.SigningKid]
+		if signing == nil || signing.Private == nil {
+			return fmt.Errorf("jwt signing key %s not found or has no private key", cfg.SigningKid)
+		}
+	} else if len(keys) > 0 {
+		return errors.New("jwt signing_kid is required when keys are configured")
+	}
+	jwtKeys, signingKey = keys, signing
+	return nil
+}
+
+// 按 token 头部的 kid 查找验证密钥
+func lookupJWTKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("jwt kid %q unknown", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public, nil
+}
+
+// JWKS 公钥集合，格式见 RFC 7517
+func JWKS() map[string]interface{} {
+	keys := make([]map[string]string, 0, len(jwtKeys))
+	for _, key := range jwtKeys {
+		jwk := map[string]string{
+			"kid": key.Kid,
+			"use": "sig",
+			"alg": key.Method.Alg(),
+		}
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i]["kid"] < keys[j]["kid"] })
+	return map[string]interface{}{"keys": keys}
+}
+
+// GenerateJWTKey 生成新的签名密钥对，写入 dir 目录，返回 kid
+func GenerateJWTKey(algorithm, dir string) (string, error) {
+	var (
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+	)
+	switch algorithm {
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", err
+		}
+		private, public = key, key.Public()
+	case "EdDSA":
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private, public = key, pub
+	default:
+		_, err := signingMethod(algorithm)
+		return "", err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	kid := time.Now().Format("20060102") + "-" + UUID()[:8]
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	if err = os.WriteFile(filepath.Join(dir, "jwt-"+kid+".pem"), privatePEM, 0600); err != nil {
+		return "", err
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	if err = os.WriteFile(filepath.Join(dir, "jwt-"+kid+".pub.pem"), publicPEM, 0644); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
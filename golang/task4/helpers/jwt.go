@@ -13,6 +13,7 @@ import (
 type MyClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Version  int    `json:"ver"` // 用户的令牌版本，退出所有设备后旧令牌失效
 	jwt.RegisteredClaims
 }
 
@@ -34,15 +35,27 @@ func GenerateToken(user models.User, exp time.Time) (string, error) {
 	cfg := system.GetConfiguration()
 	jwtSecret := []byte(cfg.JWT.SK)
 	// exp := time.Now().Add(time.Hour * 24)
+	jti, err := models.NewTokenID()
+	if err != nil {
+		return "", err
+	}
 	claims := MyClaims{
 		UserID:   user.ID,
 		Username: user.Username,
+		Version:  user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(exp),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    cfg.JWT.Issuer,
 		},
 	}
+	// 配置了非对称密钥时使用 signing_kid 对应的私钥签名
+	if signingKey != nil {
+		token := jwt.NewWithClaims(signingKey.Method, claims)
+		token.Header["kid"] = signingKey.Kid
+		return token.SignedString(signingKey.Private)
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	return token.SignedString(jwtSecret)
@@ -54,6 +67,10 @@ func ParseToken(tokenString string) (*MyClaims, error) {
 	cfg := system.GetConfiguration()
 	jwtSecret := []byte(cfg.JWT.SK)
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// 配置了非对称密钥时按 kid 查找公钥，不再接受 HS256
+		if len(jwtKeys) > 0 {
+			return lookupJWTKey(token)
+		}
 		// 确保签名算法正确
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -78,3 +95,27 @@ func ParseToken(tokenString string) (*MyClaims, error) {
 
 	return claims, nil
 }
+
+// CheckRevoked 检查 token 是否已被吊销，或签发后用户已退出所有设备
+func CheckRevoked(claims *MyClaims) error {
+	if models.IsTokenRevoked(claims.ID) {
+		return models.ErrTokenRevoked
+	}
+	user, err := models.GetUser(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.TokenVersion != claims.Version {
+		return models.ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeClaims 将 token 加入吊销列表，直至其过期
+func RevokeClaims(claims *MyClaims) error {
+	var exp time.Time
+	if claims.ExpiresAt != nil {
+		exp = claims.ExpiresAt.Time
+	}
+	return models.RevokeToken(claims.ID, claims.UserID, exp)
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// LRU 并发安全的最近最少使用缓存，超出容量时淘汰最久未访问的条目
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // 队首为最近访问
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// 命中统计
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+func NewLRU(capacity int) *LRU {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (l *LRU) Get(key string) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.order.MoveToFront(e)
+		l.hits.Add(1)
+		return e.Value.(*lruEntry).value, true
+	}
+	l.misses.Add(1)
+	return nil, false
+}
+
+func (l *LRU) Set(key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		e.Value.(*lruEntry).value = value
+		l.order.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value})
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Purge 清空缓存，统计数据保留
+func (l *LRU) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = map[string]*list.Element{}
+	l.order.Init()
+}
+
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) Stats() CacheStats {
+	return CacheStats{Entries: l.Len(), Hits: l.hits.Load(), Misses: l.misses.Load()}
+}
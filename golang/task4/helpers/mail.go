@@ -0,0 +1,178 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"go-blog/system"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/pkg/errors"
+)
+
+// 邮件内容，正文为纯文本
+type Mail struct {
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送接口，由配置文件中的 mail.driver 决定实现
+type Mailer interface {
+	Send(mail *Mail) error
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// FileMailer 将邮件写入 Dir 目录，每封邮件一个 .eml 文件
+type FileMailer struct {
+	Dir string
+}
+
+var (
+	mailer   Mailer
+	mailFrom string
+)
+
+// InitMailer 根据配置创建邮件发送器
+func InitMailer(cfg system.Mail) error {
+	switch cfg.Driver {
+	case "smtp":
+		if len(cfg.Host) == 0 {
+			return errors.New("mail host is required")
+		}
+		mailer = &SMTPMailer{Host: cfg.Host, Port: cfg.Port, Username: cfg.Username, Password: cfg.Password}
+	case "file", "":
+		mailer = &FileMailer{Dir: cfg.Outbox}
+	default:
+		return fmt.Errorf("mail driver %q not supported, use smtp or file", cfg.Driver)
+	}
+	mailFrom = cfg.From
+	return nil
+}
+
+// SetMailer 替换邮件发送器
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// SendMail 发送纯文本邮件，发件人取配置文件中的 mail.from
+func SendMail(to []string, subject, body string) error {
+	if mailer == nil {
+		return errors.New("mailer not initialized")
+	}
+	from := mailFrom
+	if len(from) == 0 {
+		from = "noreply@localhost"
+	}
+	return mailer.Send(&Mail{From: from, To: to, Subject: subject, Body: body})
+}
+
+// SendMailAsync 在后台发送邮件，失败时记录日志
+func SendMailAsync(to []string, subject, body string) {
+	go func() {
+		if err := SendMail(to, subject, body); err != nil {
+			seelog.Errorf("send mail %q to %v err: %v", subject, to, err)
+		}
+	}()
+}
+
+// NotifyAdmins 向配置文件中 notify_emails 的地址（逗号分隔）发送提醒
+func NotifyAdmins(subject, body string) {
+	cfg := system.GetConfiguration()
+	if cfg == nil {
+		return
+	}
+	var to []string
+	for _, addr := range strings.Split(cfg.NotifyEmails, ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			to = append(to, addr)
+		}
+	}
+	if len(to) > 0 {
+		SendMailAsync(to, "["+cfg.Title+"] "+subject, body)
+	}
+}
+
+// 生成 RFC 5322 格式的邮件
+func (mail *Mail) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", mail.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(mail.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+func (m *SMTPMailer) Send(mail *Mail) error {
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	var auth smtp.Auth
+	if len(m.Username) > 0 {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	if m.Port != 465 {
+		// smtp.SendMail 在服务器支持时自动启用 STARTTLS
+		return smtp.SendMail(addr, auth, mail.From, mail.To, mail.Bytes())
+	}
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: m.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(mail.From); err != nil {
+		return err
+	}
+	for _, to := range mail.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(mail.Bytes()); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (m *FileMailer) Send(mail *Mail) error {
+	if err := os.MkdirAll(m.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), UUID()[:8])
+	return os.WriteFile(filepath.Join(m.Dir, name), mail.Bytes(), 0644)
+}
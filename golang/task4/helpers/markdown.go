@@ -0,0 +1,179 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday"
+)
+
+// 与 blackfriday.MarkdownCommon 相同的选项
+const (
+	markdownHTMLFlags = blackfriday.HTML_USE_XHTML |
+		blackfriday.HTML_USE_SMARTYPANTS |
+		blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+		blackfriday.HTML_SMARTYPANTS_DASHES |
+		blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
+	markdownExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+		blackfriday.EXTENSION_TABLES |
+		blackfriday.EXTENSION_FENCED_CODE |
+		blackfriday.EXTENSION_AUTOLINK |
+		blackfriday.EXTENSION_STRIKETHROUGH |
+		blackfriday.EXTENSION_SPACE_HEADERS |
+		blackfriday.EXTENSION_HEADER_IDS |
+		blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+		blackfriday.EXTENSION_DEFINITION_LISTS
+)
+
+// 代码高亮的配色，样式表见 static/css/highlight.css
+const HighlightStyle = "github"
+
+// 目录项
+type TOCItem struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// RenderedMarkdown 渲染结果，HTML 已经过白名单过滤
+type RenderedMarkdown struct {
+	HTML string
+	TOC  []TOCItem
+}
+
+// TOCJSON 以 JSON 保存的目录
+func (r RenderedMarkdown) TOCJSON() string {
+	if len(r.TOC) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(r.TOC)
+	return string(data)
+}
+
+// ParseTOC 读取 TOCJSON 保存的目录
+func ParseTOC(data string) []TOCItem {
+	var toc []TOCItem
+	if len(data) > 0 {
+		json.Unmarshal([]byte(data), &toc)
+	}
+	return toc
+}
+
+// 在 blackfriday 的 HTML 渲染器基础上，为标题生成锚点并记录目录，为代码块生成高亮
+type markdownRenderer struct {
+	blackfriday.Renderer
+	toc []TOCItem
+	ids map[string]int
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// PlainText 去除 HTML 标签，返回纯文本
+func PlainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(tagRegexp.ReplaceAllString(s, "")))
+}
+
+// 标题锚点：保留字母、数字（含中文），其余字符替换为 -
+func headingID(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	id := strings.TrimSuffix(b.String(), "-")
+	if len(id) == 0 {
+		id = "section"
+	}
+	return id
+}
+
+func (r *markdownRenderer) Header(out *bytes.Buffer, text func() bool, level int, id string) {
+	marker := out.Len()
+	if !text() {
+		out.Truncate(marker)
+		return
+	}
+	inner := string(out.Bytes()[marker:])
+	out.Truncate(marker)
+
+	title := PlainText(inner)
+	if len(id) == 0 {
+		id = headingID(title)
+	}
+	// 相同标题依次加上 -1、-2
+	if n := r.ids[id]; n > 0 {
+		r.ids[id] = n + 1
+		id = fmt.Sprintf("%s-%d", id, n)
+	} else {
+		r.ids[id] = 1
+	}
+	r.toc = append(r.toc, TOCItem{Level: level, ID: id, Title: title})
+	fmt.Fprintf(out, "<h%d id=\"%s\"><a class=\"anchor\" href=\"#%s\">#</a>%s</h%d>\n", level, html.EscapeString(id), html.EscapeString(id), inner, level)
+}
+
+var highlightFormatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix("hl-"))
+
+func (r *markdownRenderer) BlockCode(out *bytes.Buffer, text []byte, lang string) {
+	lang = strings.Fields(lang + " ")[0]
+	var lexer chroma.Lexer
+	if len(lang) > 0 {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		r.Renderer.BlockCode(out, text, lang)
+		return
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(text))
+	if err != nil {
+		r.Renderer.BlockCode(out, text, lang)
+		return
+	}
+	marker := out.Len()
+	if err = highlightFormatter.Format(out, styles.Get(HighlightStyle), iterator); err != nil {
+		out.Truncate(marker)
+		r.Renderer.BlockCode(out, text, lang)
+	}
+}
+
+// 允许的标签与属性：在 UGC 策略的基础上允许标题锚点与高亮使用的 class
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w -]+$`)).OnElements("pre", "code", "span", "a")
+	p.RequireNoReferrerOnLinks(true)
+	return p
+}()
+
+// SanitizeHTML 按白名单过滤 HTML，去除脚本、事件属性与不安全的链接
+func SanitizeHTML(s string) string {
+	return markdownPolicy.Sanitize(s)
+}
+
+// RenderMarkdown 渲染文章内容：生成标题锚点与目录、代码高亮，并按白名单过滤 HTML
+func RenderMarkdown(source string) RenderedMarkdown {
+	renderer := &markdownRenderer{
+		Renderer: blackfriday.HtmlRenderer(markdownHTMLFlags, "", ""),
+		ids:      map[string]int{},
+	}
+	output := blackfriday.MarkdownOptions([]byte(source), renderer, blackfriday.Options{Extensions: markdownExtensions})
+	return RenderedMarkdown{
+		HTML: SanitizeHTML(string(output)),
+		TOC:  renderer.toc,
+	}
+}
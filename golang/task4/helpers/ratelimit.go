@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter 固定时间窗口的限流，每个键在一个窗口内最多允许 limit 次
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// 记录数超过此值时清理已过期的窗口
+const rateLimiterPruneSize = 10000
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, windows: map[string]*rateWindow{}}
+}
+
+// Allow 记录一次请求，超过限制时返回 false
+func (l *RateLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.windows) > rateLimiterPruneSize {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+	}
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
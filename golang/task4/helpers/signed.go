@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"go-blog/system"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 邮件链接中使用的令牌用途
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+var ErrSignedTokenInvalid = errors.New("link invalid or expired")
+
+// 签名覆盖用途、用户、过期时间与用户当前状态（邮箱或密码哈希），状态变化后令牌自动失效
+func signUserToken(purpose string, userID uint, expiresAt int64, state string) string {
+	mac := hmac.New(sha256.New, []byte(system.GetConfiguration().SessionSecret))
+	fmt.Fprintf(mac, "%s|%d|%d|%s", purpose, userID, expiresAt, state)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignUserToken 生成签名令牌，格式为 {userID}.{expiresAt}.{signature}
+func SignUserToken(purpose string, userID uint, state string, expiresAt time.Time) string {
+	exp := expiresAt.Unix()
+	return fmt.Sprintf("%d.%d.%s", userID, exp, signUserToken(purpose, userID, exp, state))
+}
+
+func parseUserToken(token string) (userID uint, expiresAt int64, signature string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, 0, "", ErrSignedTokenInvalid
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, "", ErrSignedTokenInvalid
+	}
+	if expiresAt, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, "", ErrSignedTokenInvalid
+	}
+	return uint(id), expiresAt, parts[2], nil
+}
+
+// UserIDFromToken 读取令牌中的用户 ID，签名需再通过 VerifyUserToken 校验
+func UserIDFromToken(token string) (uint, error) {
+	userID, _, _, err := parseUserToken(token)
+	return userID, err
+}
+
+// VerifyUserToken 校验令牌的用途、签名与有效期
+func VerifyUserToken(purpose, token, state string, now time.Time) error {
+	userID, expiresAt, signature, err := parseUserToken(token)
+	if err != nil {
+		return err
+	}
+	expected := signUserToken(purpose, userID, expiresAt, state)
+	if !hmac.Equal([]byte(signature), []byte(expected)) || now.Unix() > expiresAt {
+		return ErrSignedTokenInvalid
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+const siweHeader = " wants you to sign in with your Ethereum account:"
+
+var (
+	ErrSiweMessageInvalid   = errors.New("siwe message invalid")
+	ErrSiweSignatureInvalid = errors.New("siwe signature invalid")
+)
+
+// SiweMessage EIP-4361 登录消息
+type SiweMessage struct {
+	Scheme         string // 可选，如 https
+	Domain         string
+	Address        string // EIP-55 校验和格式
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseSiweMessage 按 EIP-4361 格式解析登录消息
+func ParseSiweMessage(message string) (*SiweMessage, error) {
+	lines := strings.Split(message, "\n")
+	if len(lines) < 8 || !strings.HasSuffix(lines[0], siweHeader) {
+		return nil, ErrSiweMessageInvalid
+	}
+	msg := &SiweMessage{
+		Domain:  strings.TrimSuffix(lines[0], siweHeader),
+		Address: lines[1],
+	}
+	if scheme, domain, ok := strings.Cut(msg.Domain, "://"); ok {
+		msg.Scheme, msg.Domain = scheme, domain
+	}
+	if len(msg.Domain) == 0 || !common.IsHexAddress(msg.Address) || common.HexToAddress(msg.Address).Hex() != msg.Address {
+		return nil, errors.Wrap(ErrSiweMessageInvalid, "address must be EIP-55 checksummed")
+	}
+	if lines[2] != "" {
+		return nil, ErrSiweMessageInvalid
+	}
+	// 地址之后为空行，可选的 statement，再一个空行
+	i := 3
+	if lines[i] != "" {
+		msg.Statement = lines[i]
+		i++
+	}
+	if i >= len(lines) || lines[i] != "" {
+		return nil, ErrSiweMessageInvalid
+	}
+	i++
+
+	fields := []string{"URI", "Version", "Chain ID", "Nonce", "Issued At", "Expiration Time", "Not Before", "Request ID", "Resources"}
+	values := map[string]string{}
+	next := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		matched := false
+		for next < len(fields) {
+			field := fields[next]
+			next++
+			if field == "Resources" && line == "Resources:" {
+				for i++; i < len(lines); i++ {
+					if !strings.HasPrefix(lines[i], "- ") {
+						return nil, ErrSiweMessageInvalid
+					}
+					msg.Resources = append(msg.Resources, strings.TrimPrefix(lines[i], "- "))
+				}
+				matched = true
+				break
+			}
+			if value, ok := strings.CutPrefix(line, field+": "); ok {
+				values[field] = value
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, errors.Wrapf(ErrSiweMessageInvalid, "unexpected line %q", line)
+		}
+	}
+
+	msg.URI, msg.Version, msg.Nonce, msg.RequestID = values["URI"], values["Version"], values["Nonce"], values["Request ID"]
+	if len(msg.URI) == 0 || msg.Version != "1" || len(msg.Nonce) < 8 {
+		return nil, ErrSiweMessageInvalid
+	}
+	chainID, err := strconv.ParseInt(values["Chain ID"], 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(ErrSiweMessageInvalid, "chain id")
+	}
+	msg.ChainID = chainID
+	if msg.IssuedAt, err = time.Parse(time.RFC3339, values["Issued At"]); err != nil {
+		return nil, errors.Wrap(ErrSiweMessageInvalid, "issued at")
+	}
+	for field, target := range map[string]**time.Time{"Expiration Time": &msg.ExpirationTime, "Not Before": &msg.NotBefore} {
+		if value, ok := values[field]; ok {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.Wrap(ErrSiweMessageInvalid, strings.ToLower(field))
+			}
+			*target = &t
+		}
+	}
+	return msg, nil
+}
+
+// String 生成 EIP-4361 格式的消息文本
+func (msg *SiweMessage) String() string {
+	var b strings.Builder
+	if len(msg.Scheme) > 0 {
+		b.WriteString(msg.Scheme + "://")
+	}
+	fmt.Fprintf(&b, "%s%s\n%s\n\n", msg.Domain, siweHeader, msg.Address)
+	if len(msg.Statement) > 0 {
+		b.WriteString(msg.Statement + "\n")
+	}
+	fmt.Fprintf(&b, "\nURI: %s\nVersion: %s\nChain ID: %d\nNonce: %s\nIssued At: %s",
+		msg.URI, msg.Version, msg.ChainID, msg.Nonce, msg.IssuedAt.Format(time.RFC3339))
+	if msg.ExpirationTime != nil {
+		b.WriteString("\nExpiration Time: " + msg.ExpirationTime.Format(time.RFC3339))
+	}
+	if msg.NotBefore != nil {
+		b.WriteString("\nNot Before: " + msg.NotBefore.Format(time.RFC3339))
+	}
+	if len(msg.RequestID) > 0 {
+		b.WriteString("\nRequest ID: " + msg.RequestID)
+	}
+	if len(msg.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range msg.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+	return b.String()
+}
+
+// 登录消息的校验规则
+type SiweRules struct {
+	Domain   string        // 允许的域名
+	ChainIDs []int64       // 允许的链 ID
+	MaxAge   time.Duration // Issued At 距今的最长时间
+	Skew     time.Duration // 允许的时钟偏差
+}
+
+// Valid 校验消息的域名、链 ID、签发时间与有效期
+func (msg *SiweMessage) Valid(rules SiweRules, now time.Time) error {
+	if !strings.EqualFold(msg.Domain, rules.Domain) {
+		return errors.Wrapf(ErrSiweMessageInvalid, "domain %s not allowed", msg.Domain)
+	}
+	chainAllowed := false
+	for _, id := range rules.ChainIDs {
+		if msg.ChainID == id {
+			chainAllowed = true
+		}
+	}
+	if !chainAllowed {
+		return errors.Wrapf(ErrSiweMessageInvalid, "chain id %d not allowed", msg.ChainID)
+	}
+	if msg.IssuedAt.After(now.Add(rules.Skew)) {
+		return errors.Wrap(ErrSiweMessageInvalid, "message issued in the future")
+	}
+	if rules.MaxAge > 0 && now.Sub(msg.IssuedAt) > rules.MaxAge+rules.Skew {
+		return errors.Wrap(ErrSiweMessageInvalid, "message issued too long ago")
+	}
+	if msg.ExpirationTime != nil && !now.Before(msg.ExpirationTime.Add(rules.Skew)) {
+		return errors.Wrap(ErrSiweMessageInvalid, "message expired")
+	}
+	if msg.NotBefore != nil && now.Add(rules.Skew).Before(*msg.NotBefore) {
+		return errors.Wrap(ErrSiweMessageInvalid, "message not yet valid")
+	}
+	return nil
+}
+
+// personal_sign 签名的消息哈希，见 EIP-191
+func personalHash(message string) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+}
+
+// RecoverPersonalSign 从 personal_sign 签名中恢复签名地址
+func RecoverPersonalSign(message, signature string) (common.Address, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, ErrSiweSignatureInvalid
+	}
+	// 钱包返回的 v 为 27/28，go-ethereum 需要 0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(personalHash(message), sig)
+	if err != nil {
+		return common.Address{}, ErrSiweSignatureInvalid
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
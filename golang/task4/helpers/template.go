@@ -1,6 +1,10 @@
 package helpers
 
 import (
+	"go-blog/models"
+	"html"
+	"html/template"
+	"strings"
 	"time"
 )
 
@@ -39,3 +43,11 @@ func Add(a1, a2 int) int {
 func Minus(a1, a2 int) int {
 	return a1 - a2
 }
+
+// Highlight 转义检索结果，并将高亮标记替换为 <mark> 标签
+func Highlight(source string) template.HTML {
+	return template.HTML(strings.NewReplacer(
+		models.HighlightOpen, "<mark>",
+		models.HighlightClose, "</mark>",
+	).Replace(html.EscapeString(source)))
+}
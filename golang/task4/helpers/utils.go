@@ -198,3 +198,11 @@ func RandomEmail() (string, error) {
 
 	return string(prefix) + domains[domainIdx.Int64()], nil
 }
+
+// 爬虫、预览抓取与命令行工具的 User-Agent
+var botRegexp = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|archiver|fetch|scrape|preview|facebookexternalhit|headless|lighthouse|curl|wget|python-|go-http-client|java/|okhttp|httpclient|libwww|feed`)
+
+// IsBot 判断请求是否来自爬虫，空 User-Agent 也视为爬虫
+func IsBot(userAgent string) bool {
+	return len(userAgent) == 0 || botRegexp.MatchString(userAgent)
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// 孤立附件的清理间隔
+const attachmentCleanInterval = time.Hour
+
+// RunAttachmentCleaner 后台定时删除没有被文章引用且超过保留期的附件，collect 负责删除记录与存储中的文件
+func RunAttachmentCleaner(ctx context.Context, collect func(now time.Time) (int, error)) {
+	ticker := time.NewTicker(attachmentCleanInterval)
+	defer ticker.Stop()
+	for {
+		count, err := collect(time.Now())
+		if err != nil {
+			seelog.Errorf("attachment cleaner err: %v", err)
+		} else if count > 0 {
+			seelog.Infof("attachment cleaner: %d orphaned attachment(s) removed", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
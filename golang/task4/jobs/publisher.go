@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"go-blog/models"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// 两次检查之间的最长等待时间
+const publisherMaxWait = time.Minute
+
+var publisherWake = make(chan struct{}, 1)
+
+// WakePublisher 在文章设置为定时发布后调用，使发布任务重新计算下一次发布时间
+func WakePublisher() {
+	select {
+	case publisherWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunPublisher 后台定时发布任务：到达发布时间的文章由 scheduled 切换为 published
+func RunPublisher(ctx context.Context) {
+	for {
+		count, err := models.PublishDuePosts(time.Now())
+		if err != nil {
+			seelog.Errorf("models.PublishDuePosts err: %v", err)
+		} else if count > 0 {
+			seelog.Infof("publisher: %d scheduled post(s) published", count)
+		}
+
+		wait := publisherMaxWait
+		if next, err := models.NextScheduledAt(); err == nil && next != nil {
+			if d := time.Until(*next); d < wait {
+				wait = d
+			}
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-publisherWake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
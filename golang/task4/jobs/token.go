@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"go-blog/models"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// 过期令牌的清理间隔
+const tokenCleanInterval = time.Hour
+
+// RunTokenCleaner 后台定时清理过期的刷新令牌与吊销记录
+func RunTokenCleaner(ctx context.Context) {
+	ticker := time.NewTicker(tokenCleanInterval)
+	defer ticker.Stop()
+	for {
+		count, err := models.PurgeExpiredTokens(time.Now())
+		if err != nil {
+			seelog.Errorf("models.PurgeExpiredTokens err: %v", err)
+		} else if count > 0 {
+			seelog.Infof("token cleaner: %d expired token(s) purged", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"go-blog/models"
+	"go-blog/system"
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// 阅读数缓冲：去重后的阅读先累加在内存中，由后台任务批量写入数据库
+type viewBuffer struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time // 访客与文章 → 去重截止时间
+	pending map[uint]int         // 文章 → 尚未写入的阅读数
+	total   int
+}
+
+var (
+	views     = &viewBuffer{seen: map[string]time.Time{}, pending: map[uint]int{}}
+	viewsWake = make(chan struct{}, 1)
+)
+
+func viewsConfig() system.PostViews {
+	cfg := system.PostViews{WindowSeconds: 1800, FlushSeconds: 30, BatchSize: 1000}
+	if c := system.GetConfiguration(); c != nil {
+		if c.PostViews.WindowSeconds > 0 {
+			cfg.WindowSeconds = c.PostViews.WindowSeconds
+		}
+		if c.PostViews.FlushSeconds > 0 {
+			cfg.FlushSeconds = c.PostViews.FlushSeconds
+		}
+		if c.PostViews.BatchSize > 0 {
+			cfg.BatchSize = c.PostViews.BatchSize
+		}
+	}
+	return cfg
+}
+
+// RecordView 记录一次阅读，同一访客在去重时间内重复阅读同一文章时返回 false
+func RecordView(postID uint, visitor string, now time.Time) bool {
+	cfg := viewsConfig()
+	key := fmt.Sprintf("%s:%d", visitor, postID)
+
+	views.mu.Lock()
+	if until, ok := views.seen[key]; ok && now.Before(until) {
+		views.mu.Unlock()
+		return false
+	}
+	views.seen[key] = now.Add(time.Duration(cfg.WindowSeconds) * time.Second)
+	views.pending[postID]++
+	views.total++
+	full := views.total >= cfg.BatchSize
+	views.mu.Unlock()
+
+	if full {
+		select {
+		case viewsWake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// PendingViews 尚未写入数据库的阅读数
+func PendingViews(postID uint) int {
+	views.mu.Lock()
+	defer views.mu.Unlock()
+	return views.pending[postID]
+}
+
+// FlushViews 将缓冲的阅读数写入数据库，并清理过期的去重记录；写入失败时阅读数放回缓冲区
+func FlushViews(now time.Time) (int, error) {
+	views.mu.Lock()
+	pending, total := views.pending, views.total
+	views.pending, views.total = map[uint]int{}, 0
+	for key, until := range views.seen {
+		if !now.Before(until) {
+			delete(views.seen, key)
+		}
+	}
+	views.mu.Unlock()
+
+	if total == 0 {
+		return 0, nil
+	}
+	if err := models.AddPostViews(pending, now); err != nil {
+		views.mu.Lock()
+		for id, count := range pending {
+			views.pending[id] += count
+		}
+		views.total += total
+		views.mu.Unlock()
+		return 0, err
+	}
+	return total, nil
+}
+
+// RunViewFlusher 后台定时写入阅读数，写入后调用 flushed（如清除页面缓存）；退出前写入剩余的阅读数
+func RunViewFlusher(ctx context.Context, flushed func()) {
+	ticker := time.NewTicker(time.Duration(viewsConfig().FlushSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		stop := false
+		select {
+		case <-ctx.Done():
+			stop = true
+		case <-viewsWake:
+		case <-ticker.C:
+		}
+
+		count, err := FlushViews(time.Now())
+		if err != nil {
+			seelog.Errorf("jobs.FlushViews err: %v", err)
+		} else if count > 0 && flushed != nil {
+			flushed()
+		}
+		if stop {
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 某一年的归档汇总
+type ArchiveYear struct {
+	Year   int
+	Total  int
+	Months []*QrArchive // 按月份倒序
+}
+
+// 某一天发布的文章数
+type ArchiveDay struct {
+	Date  string `json:"date"` // 2006-01-02
+	Day   int    `json:"day"`
+	Total int    `json:"total"`
+}
+
+// 按发布时间筛选某年或某月（month 为 0 时按年）的文章，与 ListPostArchives 的分组方式一致
+func archiveScope(year, month int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if month > 0 {
+			return db.Where("strftime('%Y-%m', posts.published_at) = ?", fmt.Sprintf("%04d-%02d", year, month))
+		}
+		return db.Where("strftime('%Y', posts.published_at) = ?", fmt.Sprintf("%04d", year))
+	}
+}
+
+// 归档文章列表，按发布时间倒序，pageIndex 从 1 开始
+func ListPostByArchive(year, month, pageIndex, pageSize int) ([]*Post, error) {
+	var posts []*Post
+	db := DB.Preload("Tags").Preload("Categories").
+		Scopes(Published, archiveScope(year, month)).
+		Order("posts.published_at desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&posts).Error
+	return posts, err
+}
+
+func CountPostByArchive(year, month int) (int, error) {
+	var count int64
+	err := DB.Model(&Post{}).Scopes(Published, archiveScope(year, month)).Count(&count).Error
+	return int(count), err
+}
+
+// ListArchiveYears 按年汇总的归档，年份倒序
+func ListArchiveYears() ([]*ArchiveYear, error) {
+	archives, err := ListPostArchives()
+	if err != nil {
+		return nil, err
+	}
+	var years []*ArchiveYear
+	for _, archive := range archives {
+		if len(years) == 0 || years[len(years)-1].Year != archive.Year {
+			years = append(years, &ArchiveYear{Year: archive.Year})
+		}
+		year := years[len(years)-1]
+		year.Total += archive.Total
+		year.Months = append(year.Months, archive)
+	}
+	return years, nil
+}
+
+// ListArchiveDays 某月每天发布的文章数，只包含有文章的日期
+func ListArchiveDays(year, month int) ([]*ArchiveDay, error) {
+	var days []*ArchiveDay
+	rows, err := DB.Model(&Post{}).Scopes(Published, archiveScope(year, month)).
+		Select("strftime('%Y-%m-%d', posts.published_at) AS date, count(*) AS total").
+		Group("date").Order("date").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day ArchiveDay
+		if err = rows.Scan(&day.Date, &day.Total); err != nil {
+			return nil, err
+		}
+		fmt.Sscanf(day.Date[len(day.Date)-2:], "%d", &day.Day)
+		days = append(days, &day)
+	}
+	return days, rows.Err()
+}
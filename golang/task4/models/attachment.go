@@ -0,0 +1,283 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/russross/blackfriday"
+	"gorm.io/gorm"
+)
+
+var ErrAttachmentInUse = errors.New("attachment is referenced by posts")
+
+// 附件，文件以内容哈希命名，全站同一文件只有一条记录
+type Attachment struct {
+	gorm.Model
+	UserID      uint `gorm:"index"` // 上传者
+	User        User
+	Hash        string `gorm:"type:varchar(64);not null;uniqueIndex"` // 内容的 SHA-256
+	Key         string `gorm:"not null"`                              // 存储中的文件名
+	URL         string `gorm:"not null"`
+	Filename    string // 原始文件名
+	ContentType string `gorm:"type:varchar(128)"`
+	Size        int64
+	Width       int
+	Height      int
+	Variants    string     `gorm:"type:text"` // 缩放版本，JSON
+	DetachedAt  *time.Time // 最近一次不再被文章引用的时间，孤立文件从此时起计算保留期
+	Posts       []Post     `gorm:"many2many:post_attachments"`
+	RefCount    int        `gorm:"->"` // 引用该附件的文章数
+}
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
+
+// 附件的缩放版本
+type AttachmentVariant struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (a *Attachment) VariantList() []AttachmentVariant {
+	var variants []AttachmentVariant
+	if len(a.Variants) > 0 {
+		json.Unmarshal([]byte(a.Variants), &variants)
+	}
+	return variants
+}
+
+func (a *Attachment) SetVariants(variants []AttachmentVariant) {
+	if len(variants) == 0 {
+		a.Variants = ""
+		return
+	}
+	data, _ := json.Marshal(variants)
+	a.Variants = string(data)
+}
+
+// Thumbnail 列表中显示的预览图，取最小的缩放版本
+func (a *Attachment) Thumbnail() string {
+	if variants := a.VariantList(); len(variants) > 0 {
+		return variants[0].URL
+	}
+	return a.URL
+}
+
+// HumanSize 以 KB、MB 显示的文件大小
+func (a *Attachment) HumanSize() string {
+	switch {
+	case a.Size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(a.Size)/(1<<20))
+	case a.Size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(a.Size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", a.Size)
+}
+
+// Keys 原图与各缩放版本在存储中的文件名
+func (a *Attachment) Keys() []string {
+	keys := []string{a.Key}
+	for _, v := range a.VariantList() {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+// 未删除的文章对附件的引用数
+const attachmentRefCount = "(SELECT COUNT(*) FROM post_attachments pa INNER JOIN posts p ON p.id = pa.post_id AND p.deleted_at IS NULL WHERE pa.attachment_id = attachments.id)"
+
+// SaveAttachment 记录上传的文件。相同内容已有记录时返回已有记录，上传者仍为首次上传的用户；
+// 已有记录未被文章引用时重新计算孤立文件的保留期，避免在保存文章前被清理，仍被引用时保持不变
+func SaveAttachment(a *Attachment) (*Attachment, error) {
+	var existing Attachment
+	err := DB.Where("hash = ?", a.Hash).First(&existing).Error
+	if err == nil {
+		now := time.Now()
+		result := DB.Model(&Attachment{}).
+			Where("id = ? AND "+attachmentRefCount+" = 0", existing.ID).
+			Update("detached_at", now)
+		if result.RowsAffected > 0 {
+			existing.DetachedAt = &now
+		}
+		return &existing, result.Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return a, DB.Create(a).Error
+}
+
+func GetAttachment(id uint) (*Attachment, error) {
+	var a Attachment
+	err := DB.Preload("User").Select("attachments.*, "+attachmentRefCount+" AS ref_count").First(&a, "id = ?", id).Error
+	return &a, err
+}
+
+// 附件列表，userID 为 0 时列出全部
+func attachmentQuery(userID uint) *gorm.DB {
+	query := DB.Model(&Attachment{})
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	return query
+}
+
+func ListAttachment(userID uint, pageIndex, pageSize int) ([]*Attachment, error) {
+	var attachments []*Attachment
+	err := attachmentQuery(userID).Preload("User").
+		Select("attachments.*, " + attachmentRefCount + " AS ref_count").
+		Order("id desc").Offset((pageIndex - 1) * pageSize).Limit(pageSize).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func CountAttachment(userID uint) (int64, error) {
+	var count int64
+	err := attachmentQuery(userID).Count(&count).Error
+	return count, err
+}
+
+// 引用该附件的文章
+func (a *Attachment) ReferencedPosts() ([]*Post, error) {
+	var posts []*Post
+	err := DB.Joins("INNER JOIN post_attachments pa ON pa.post_id = posts.id").
+		Where("pa.attachment_id = ?", a.ID).Order("posts.id desc").Find(&posts).Error
+	return posts, err
+}
+
+// Purge 删除附件记录，仍被文章引用时返回 ErrAttachmentInUse；存储中的文件由调用方删除
+func (a *Attachment) Purge() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Attachment{}).Where("id = ? AND "+attachmentRefCount+" > 0", a.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAttachmentInUse
+		}
+		// 已删除文章的引用一并清除
+		if err := tx.Exec("DELETE FROM post_attachments WHERE attachment_id = ?", a.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(a).Error
+	})
+}
+
+// 渲染结果中的链接属性
+var linkAttrRegexp = regexp.MustCompile(`(?i)\s(src|href|srcset|poster)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// 返回 Markdown 中引用的所有地址，包括图片、链接（含引用式链接）与内嵌 HTML 中的 src/href/srcset
+func markdownURLs(content string) []string {
+	rendered := blackfriday.MarkdownCommon([]byte(content))
+	var urls []string
+	seen := map[string]bool{}
+	for _, match := range linkAttrRegexp.FindAllSubmatch(rendered, -1) {
+		items := []string{html.UnescapeString(string(match[2]) + string(match[3]))}
+		// srcset 为逗号分隔的 "地址 宽度" 列表
+		if strings.EqualFold(string(match[1]), "srcset") {
+			items = strings.Split(items[0], ",")
+		}
+		for _, item := range items {
+			fields := strings.Fields(item)
+			if len(fields) == 0 || seen[fields[0]] {
+				continue
+			}
+			seen[fields[0]] = true
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// 文件名中的内容哈希，缩放版本为 {哈希}-{名称}{扩展名}
+var attachmentHashRegexp = regexp.MustCompile(`^([0-9a-f]{64})(?:-[\w-]+)?(?:\.\w+)?$`)
+
+// 从文章内容中找出引用的附件哈希
+func attachmentHashes(content string) []string {
+	var hashes []string
+	for _, u := range markdownURLs(content) {
+		if m := attachmentHashRegexp.FindStringSubmatch(path.Base(u)); m != nil {
+			hashes = append(hashes, m[1])
+		}
+	}
+	return hashes
+}
+
+// MissingAttachmentURLs 内容中指向已被清理的附件的地址，恢复修订版本后用于提示重新上传
+func MissingAttachmentURLs(content string) ([]string, error) {
+	var (
+		hashes []string
+		urls   = map[string][]string{}
+	)
+	for _, u := range markdownURLs(content) {
+		if m := attachmentHashRegexp.FindStringSubmatch(path.Base(u)); m != nil {
+			if _, ok := urls[m[1]]; !ok {
+				hashes = append(hashes, m[1])
+			}
+			urls[m[1]] = append(urls[m[1]], u)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	var existing []string
+	if err := DB.Model(&Attachment{}).Where("hash IN ?", hashes).Pluck("hash", &existing).Error; err != nil {
+		return nil, err
+	}
+	for _, hash := range existing {
+		delete(urls, hash)
+	}
+	var missing []string
+	for _, hash := range hashes {
+		missing = append(missing, urls[hash]...)
+	}
+	return missing, nil
+}
+
+// 解析文章内容，更新文章引用的附件；不再被引用的附件记录移除时间
+func syncAttachments(db *gorm.DB, post *Post) error {
+	var attachments []Attachment
+	if hashes := attachmentHashes(post.Content); len(hashes) > 0 {
+		if err := db.Where("hash IN ?", hashes).Find(&attachments).Error; err != nil {
+			return err
+		}
+	}
+	keep := make([]uint, 0, len(attachments))
+	for _, a := range attachments {
+		keep = append(keep, a.ID)
+	}
+	detached := db.Model(&Attachment{}).Where("id IN (?)", db.Table("post_attachments").Select("attachment_id").Where("post_id = ?", post.ID))
+	if len(keep) > 0 {
+		detached = detached.Where("id NOT IN ?", keep)
+	}
+	if err := detached.Update("detached_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return db.Model(post).Association("Attachments").Replace(attachments)
+}
+
+// 文章删除后，其引用的附件开始计算保留期
+func detachPostAttachments(db *gorm.DB, postID uint) error {
+	return db.Model(&Attachment{}).
+		Where("id IN (?)", db.Table("post_attachments").Select("attachment_id").Where("post_id = ?", postID)).
+		Update("detached_at", time.Now()).Error
+}
+
+// ListOrphanAttachment 没有被任何文章引用、且超过保留期的附件
+func ListOrphanAttachment(before time.Time) ([]*Attachment, error) {
+	var attachments []*Attachment
+	err := DB.Where(attachmentRefCount+" = 0").
+		Where("created_at < ? AND (detached_at IS NULL OR detached_at < ?)", before, before).
+		Find(&attachments).Error
+	return attachments, err
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"go-blog/system"
+)
+
+// 默认评论最大嵌套层数
+const defaultCommentMaxDepth = 3
+
+var (
+	ErrCommentParentMismatch = errors.New("parent comment does not belong to this post")
+	ErrCommentTooDeep        = errors.New("comment nesting exceeds the maximum depth")
+)
+
+// CommentMaxDepth 评论最大嵌套层数，取配置文件中的 comment_max_depth
+func CommentMaxDepth() int {
+	if cfg := system.GetConfiguration(); cfg != nil && cfg.CommentMaxDepth > 0 {
+		return cfg.CommentMaxDepth
+	}
+	return defaultCommentMaxDepth
+}
+
+// CanReply 未达到最大嵌套层数的评论才能被回复
+func (comment *Comment) CanReply() bool {
+	return comment.Depth+1 < CommentMaxDepth()
+}
+
+// SetParent 设置回复的上级评论，上级评论必须属于同一篇文章且未达到最大嵌套层数
+func (comment *Comment) SetParent(parent *Comment) error {
+	if parent.PostID != comment.PostID {
+		return ErrCommentParentMismatch
+	}
+	if !parent.CanReply() {
+		return ErrCommentTooDeep
+	}
+	comment.ParentID = &parent.ID
+	comment.Depth = parent.Depth + 1
+	return nil
+}
+
+// ListCommentTreeByPostID 查询文章审核通过的评论并按回复关系组织为树，同级评论按时间正序；
+// 上级评论已删除或未通过审核的回复提升为顶级评论
+func ListCommentTreeByPostID(id uint) ([]*Comment, error) {
+	var comments []*Comment
+	err := DB.Preload("User").
+		Where("post_id = ?", id).
+		Scopes(Approved).
+		Order("created_at, id").
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[uint]*Comment, len(comments))
+	for _, comment := range comments {
+		nodes[comment.ID] = comment
+	}
+	roots := make([]*Comment, 0, len(comments))
+	for _, comment := range comments {
+		if comment.ParentID != nil {
+			if parent, ok := nodes[*comment.ParentID]; ok {
+				parent.Children = append(parent.Children, comment)
+				continue
+			}
+		}
+		roots = append(roots, comment)
+	}
+	return roots, nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 影响公开页面内容的表，写入后通知订阅者（清除页面缓存等）
+var contentTables = map[string]bool{
+	"posts":           true,
+	"comments":        true,
+	"tags":            true,
+	"categories":      true,
+	"post_tags":       true,
+	"post_categories": true,
+	"users":           true,
+}
+
+var (
+	changeMu        sync.RWMutex
+	changeListeners []func(table string)
+	// 最近一次内容变更的时间（UnixNano）
+	contentChangedAt atomic.Int64
+)
+
+// OnContentChange 订阅内容变更事件
+func OnContentChange(fn func(table string)) {
+	changeMu.Lock()
+	defer changeMu.Unlock()
+	changeListeners = append(changeListeners, fn)
+}
+
+// LastContentChange 最近一次内容变更的时间，启动后尚未变更时为注册回调的时间
+func LastContentChange() time.Time {
+	return time.Unix(0, contentChangedAt.Load())
+}
+
+// NotifyContentChange 发布内容变更事件；通过 GORM 写入的数据由回调自动发布，原生 SQL 需手动调用
+func NotifyContentChange(table string) {
+	contentChangedAt.Store(time.Now().UnixNano())
+	changeMu.RLock()
+	listeners := changeListeners
+	changeMu.RUnlock()
+	for _, fn := range listeners {
+		fn(table)
+	}
+}
+
+func afterWrite(db *gorm.DB) {
+	if db.Error == nil && db.RowsAffected > 0 && contentTables[db.Statement.Table] {
+		NotifyContentChange(db.Statement.Table)
+	}
+}
+
+// RegisterChangeCallbacks 在新增、更新、删除之后发布内容变更事件
+func RegisterChangeCallbacks(db *gorm.DB) error {
+	contentChangedAt.CompareAndSwap(0, time.Now().UnixNano())
+	if err := db.Callback().Create().After("gorm:create").Register("blog:content_change", afterWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("blog:content_change", afterWrite); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("blog:content_change", afterWrite)
+}
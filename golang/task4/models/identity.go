@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrIdentityLinked = errors.New("this account is already linked to another user")
+
+// 第三方账号与本地用户的绑定关系，同一 Provider 下 Subject 唯一
+type ProviderIdentity struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	Provider string `gorm:"type:varchar(32);not null;uniqueIndex:idx_provider_identities_subject"`
+	Subject  string `gorm:"not null;uniqueIndex:idx_provider_identities_subject"` // 第三方用户 ID
+	Login    string
+	Email    string
+}
+
+func (ProviderIdentity) TableName() string {
+	return "provider_identities"
+}
+
+// 第三方返回的用户信息
+type ExternalUser struct {
+	Provider      string
+	Subject       string
+	Login         string
+	Name          string
+	Email         string
+	EmailVerified bool
+	AvatarUrl     string
+}
+
+func GetProviderIdentity(provider, subject string) (*ProviderIdentity, error) {
+	var identity ProviderIdentity
+	err := DB.First(&identity, "provider = ? and subject = ?", provider, subject).Error
+	return &identity, err
+}
+
+func ListUserIdentity(userID uint) ([]*ProviderIdentity, error) {
+	var identities []*ProviderIdentity
+	err := DB.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+func newProviderIdentity(userID uint, ext *ExternalUser) *ProviderIdentity {
+	return &ProviderIdentity{
+		UserID:   userID,
+		Provider: ext.Provider,
+		Subject:  ext.Subject,
+		Login:    ext.Login,
+		Email:    ext.Email,
+	}
+}
+
+// LinkIdentity 将第三方账号绑定到已登录的用户
+func (user *User) LinkIdentity(ext *ExternalUser) error {
+	identity, err := GetProviderIdentity(ext.Provider, ext.Subject)
+	if err == nil {
+		if identity.UserID != user.ID {
+			return ErrIdentityLinked
+		}
+		return nil
+	}
+	return DB.Create(newProviderIdentity(user.ID, ext)).Error
+}
+
+// LoginWithIdentity 第三方登录：已绑定时返回对应用户；第三方邮箱与本地邮箱均已验证且一致时自动绑定；
+// 否则创建新用户，created 为 true
+func LoginWithIdentity(ext *ExternalUser) (user *User, created bool, err error) {
+	if identity, err := GetProviderIdentity(ext.Provider, ext.Subject); err == nil {
+		user, err = GetUser(identity.UserID)
+		return user, false, err
+	}
+	if ext.EmailVerified && len(ext.Email) > 0 {
+		if existing, err := GetUserByEmail(ext.Email); err == nil && existing.IsEmailVerified() {
+			return existing, false, existing.LinkIdentity(ext)
+		}
+	}
+	user = &User{
+		Username:  ext.Login,
+		Email:     ext.Email,
+		AvatarUrl: ext.AvatarUrl,
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		user.Username = uniqueUsername(tx, ext)
+		// 邮箱为空或已被其他用户占用时使用占位邮箱，用户可稍后修改
+		var count int64
+		tx.Model(&User{}).Where("email = ?", ext.Email).Count(&count)
+		if len(ext.Email) == 0 || count > 0 {
+			user.Email = fmt.Sprintf("%s-%s@users.noreply.invalid", ext.Provider, ext.Subject)
+		} else if ext.EmailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(newProviderIdentity(user.ID, ext)).Error
+	})
+	return user, err == nil, err
+}
+
+// 第三方用户名已被占用时，依次尝试 {login}-{provider}、{login}-{provider}-{n}
+func uniqueUsername(tx *gorm.DB, ext *ExternalUser) string {
+	base := strings.TrimSpace(ext.Login)
+	if len(base) == 0 {
+		base = ext.Provider + "-" + ext.Subject
+	}
+	candidates := []string{base, base + "-" + ext.Provider}
+	for i := 2; i < 100; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%s-%d", base, ext.Provider, i))
+	}
+	for _, name := range candidates {
+		var count int64
+		tx.Model(&User{}).Where("username = ?", name).Count(&count)
+		if count == 0 {
+			return name
+		}
+	}
+	return ext.Provider + "-" + ext.Subject
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"go-blog/system"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 登录失败计数的维度
+const (
+	LoginKeyUsername = "username"
+	LoginKeyIP       = "ip"
+)
+
+// 登录失败计数，按用户名与 IP 分别记录
+type LoginFailure struct {
+	gorm.Model
+	Kind         string `gorm:"type:varchar(16);not null;uniqueIndex:idx_login_failures_key"`
+	Key          string `gorm:"not null;uniqueIndex:idx_login_failures_key"`
+	Count        int    // 当前连续失败次数
+	Lockouts     int    // 累计锁定次数，用于计算指数退避的锁定时长
+	LockedUntil  *time.Time
+	LastFailedAt time.Time
+}
+
+func (LoginFailure) TableName() string {
+	return "login_failures"
+}
+
+// 锁定事件，供管理员查看与解除
+type LockoutEvent struct {
+	gorm.Model
+	Kind        string `gorm:"type:varchar(16);not null;index"`
+	Key         string `gorm:"not null;index"`
+	Failures    int
+	LockedUntil time.Time
+	ClearedAt   *time.Time
+	ClearedBy   uint
+}
+
+func (LockoutEvent) TableName() string {
+	return "lockout_events"
+}
+
+// 登录检查结果
+type LoginStatus struct {
+	LockedUntil     *time.Time // 不为空时禁止登录
+	CaptchaRequired bool       // 是否需要输入验证码
+}
+
+type loginPolicy struct {
+	maxFailures   int
+	ipMaxFailures int
+	captchaAfter  int
+	lockout       time.Duration
+	maxLockout    time.Duration
+}
+
+// 登录保护策略，取配置文件中的 [login] 配置
+func currentLoginPolicy() loginPolicy {
+	policy := loginPolicy{
+		maxFailures:   5,
+		ipMaxFailures: 20,
+		captchaAfter:  3,
+		lockout:       time.Minute,
+		maxLockout:    24 * time.Hour,
+	}
+	cfg := system.GetConfiguration()
+	if cfg == nil {
+		return policy
+	}
+	if cfg.Login.MaxFailures > 0 {
+		policy.maxFailures = cfg.Login.MaxFailures
+	}
+	if cfg.Login.IPMaxFailures > 0 {
+		policy.ipMaxFailures = cfg.Login.IPMaxFailures
+	}
+	if cfg.Login.CaptchaAfter > 0 {
+		policy.captchaAfter = cfg.Login.CaptchaAfter
+	}
+	if cfg.Login.LockoutSeconds > 0 {
+		policy.lockout = time.Duration(cfg.Login.LockoutSeconds) * time.Second
+	}
+	if cfg.Login.MaxLockoutSeconds > 0 {
+		policy.maxLockout = time.Duration(cfg.Login.MaxLockoutSeconds) * time.Second
+	}
+	return policy
+}
+
+func (policy loginPolicy) threshold(kind string) int {
+	if kind == LoginKeyIP {
+		return policy.ipMaxFailures
+	}
+	return policy.maxFailures
+}
+
+// 第 n 次锁定的时长：首次为 lockout，之后每次翻倍，不超过 maxLockout
+func (policy loginPolicy) lockoutDuration(n int) time.Duration {
+	d := policy.lockout
+	for i := 1; i < n && d < policy.maxLockout; i++ {
+		d *= 2
+	}
+	return min(d, policy.maxLockout)
+}
+
+func getLoginFailure(kind, key string, now time.Time, policy loginPolicy) *LoginFailure {
+	var failure LoginFailure
+	DB.Where("kind = ? and key = ?", kind, key).Limit(1).Find(&failure)
+	// 超过最长锁定时长没有失败记录时，重新开始计数
+	if failure.ID > 0 && now.Sub(failure.LastFailedAt) > policy.maxLockout {
+		failure.Count = 0
+		failure.Lockouts = 0
+		failure.LockedUntil = nil
+	}
+	return &failure
+}
+
+// CheckLogin 登录前检查用户名与 IP 是否被锁定、是否需要验证码
+func CheckLogin(username, ip string, now time.Time) LoginStatus {
+	var (
+		status LoginStatus
+		policy = currentLoginPolicy()
+	)
+	for _, item := range [][2]string{{LoginKeyUsername, username}, {LoginKeyIP, ip}} {
+		failure := getLoginFailure(item[0], item[1], now, policy)
+		if failure.ID == 0 {
+			continue
+		}
+		if failure.LockedUntil != nil && failure.LockedUntil.After(now) {
+			if status.LockedUntil == nil || failure.LockedUntil.After(*status.LockedUntil) {
+				status.LockedUntil = failure.LockedUntil
+			}
+		}
+		if failure.Count >= policy.captchaAfter || failure.Lockouts > 0 {
+			status.CaptchaRequired = true
+		}
+	}
+	return status
+}
+
+// 失败计数加一，超过最长锁定时长没有失败记录时重新开始计数；单条语句完成，并发的失败不会丢失计数
+func incrLoginFailure(kind, key string, now time.Time, policy loginPolicy) (*LoginFailure, error) {
+	stale := now.Add(-policy.maxLockout)
+	failure := &LoginFailure{Kind: kind, Key: key, Count: 1, LastFailedAt: now}
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":          gorm.Expr("CASE WHEN login_failures.last_failed_at < ? THEN 1 ELSE login_failures.count + 1 END", stale),
+			"lockouts":       gorm.Expr("CASE WHEN login_failures.last_failed_at < ? THEN 0 ELSE login_failures.lockouts END", stale),
+			"locked_until":   gorm.Expr("CASE WHEN login_failures.last_failed_at < ? THEN NULL ELSE login_failures.locked_until END", stale),
+			"last_failed_at": now,
+			"updated_at":     now,
+		}),
+	}, clause.Returning{}).Create(failure).Error
+	return failure, err
+}
+
+// RecordLoginFailure 记录一次登录失败，达到阈值时锁定并记录锁定事件
+func RecordLoginFailure(username, ip string, now time.Time) (LoginStatus, error) {
+	policy := currentLoginPolicy()
+	for _, item := range [][2]string{{LoginKeyUsername, username}, {LoginKeyIP, ip}} {
+		failure, err := incrLoginFailure(item[0], item[1], now, policy)
+		if err != nil {
+			return CheckLogin(username, ip, now), err
+		}
+		if failure.Count < policy.threshold(failure.Kind) {
+			continue
+		}
+		// 计数未被其他请求改动时才锁定，并发的失败同时达到阈值时只锁定一次
+		lockedUntil := now.Add(policy.lockoutDuration(failure.Lockouts + 1))
+		result := DB.Model(&LoginFailure{}).
+			Where("id = ? and count = ? and lockouts = ?", failure.ID, failure.Count, failure.Lockouts).
+			UpdateColumns(map[string]interface{}{"count": 0, "lockouts": failure.Lockouts + 1, "locked_until": lockedUntil})
+		if result.Error != nil {
+			return CheckLogin(username, ip, now), result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		err = DB.Create(&LockoutEvent{
+			Kind:        failure.Kind,
+			Key:         failure.Key,
+			Failures:    failure.Count,
+			LockedUntil: lockedUntil,
+		}).Error
+		if err != nil {
+			return CheckLogin(username, ip, now), err
+		}
+	}
+	return CheckLogin(username, ip, now), nil
+}
+
+// ResetLoginFailure 登录成功后清除用户名的失败计数
+func ResetLoginFailure(username string) error {
+	return DB.Unscoped().Where("kind = ? and key = ?", LoginKeyUsername, username).Delete(&LoginFailure{}).Error
+}
+
+// ListLockoutEvent 锁定事件列表，按时间倒序
+func ListLockoutEvent(pageIndex, pageSize int) ([]*LockoutEvent, error) {
+	var events []*LockoutEvent
+	db := DB.Order("id desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&events).Error
+	return events, err
+}
+
+func CountLockoutEvent() (count int64, err error) {
+	err = DB.Model(&LockoutEvent{}).Count(&count).Error
+	return
+}
+
+func GetLockoutEventById(id uint) (*LockoutEvent, error) {
+	var event LockoutEvent
+	err := DB.First(&event, "id = ?", id).Error
+	return &event, err
+}
+
+func (event *LockoutEvent) IsActive(now time.Time) bool {
+	return event.ClearedAt == nil && event.LockedUntil.After(now)
+}
+
+// Clear 解除锁定：清除对应用户名或 IP 的失败计数，并标记该维度下未解除的锁定事件
+func (event *LockoutEvent) Clear(userID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Where("kind = ? and key = ?", event.Kind, event.Key).Delete(&LoginFailure{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&LockoutEvent{}).
+			Where("kind = ? and key = ? and cleared_at is null", event.Kind, event.Key).
+			Updates(map[string]interface{}{
+				"cleared_at": time.Now(),
+				"cleared_by": userID,
+			}).Error
+	})
+}
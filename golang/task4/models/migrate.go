@@ -0,0 +1,188 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSchemaTooNew   = errors.New("database schema is newer than this build, upgrade the program first")
+	ErrSchemaOutdated = errors.New("database schema is outdated, run with -migrate up")
+)
+
+// Migration 一次数据库迁移，Up 与 Down 在同一事务中执行并记录版本
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 已执行的迁移
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// 迁移的执行状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // 未执行时为空
+}
+
+// 按版本号排序的迁移列表
+func sortedMigrations() []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// LatestSchemaVersion 当前程序包含的最新迁移版本
+func LatestSchemaVersion() int {
+	list := sortedMigrations()
+	if len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Version
+}
+
+func ensureMigrationTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+func appliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	if err := ensureMigrationTable(db); err != nil {
+		return nil, err
+	}
+	var applied []SchemaMigration
+	err := db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// SchemaVersion 数据库已执行的最新迁移版本，未执行过迁移时为 0
+func SchemaVersion(db *gorm.DB) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// CheckSchema 数据库版本高于程序时返回 ErrSchemaTooNew，有未执行的迁移时返回 ErrSchemaOutdated
+func CheckSchema(db *gorm.DB) error {
+	if err := checkNotNewer(db); err != nil {
+		return err
+	}
+	status, err := MigrationStatuses(db)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("%w (pending %d_%s)", ErrSchemaOutdated, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// MigrationStatuses 所有迁移的执行状态
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := map[int]time.Time{}
+	for _, m := range applied {
+		appliedAt[m.Version] = m.AppliedAt
+	}
+	var status []MigrationStatus
+	for _, m := range sortedMigrations() {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if t, ok := appliedAt[m.Version]; ok {
+			s.AppliedAt = &t
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// MigrateUp 依次执行未执行的迁移，返回本次执行的迁移
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	if err := checkNotNewer(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	done := map[int]bool{}
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+	var executed []Migration
+	for _, m := range sortedMigrations() {
+		if done[m.Version] {
+			continue
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return executed, fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+		executed = append(executed, m)
+	}
+	return executed, nil
+}
+
+// MigrateDown 回滚最近执行的一次迁移，没有可回滚的迁移时返回 nil
+func MigrateDown(db *gorm.DB) (*Migration, error) {
+	if err := checkNotNewer(db); err != nil {
+		return nil, err
+	}
+	version, err := SchemaVersion(db)
+	if err != nil || version == 0 {
+		return nil, err
+	}
+	for _, m := range sortedMigrations() {
+		if m.Version != version {
+			continue
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+		}
+		return &m, nil
+	}
+	return nil, fmt.Errorf("migration %d not found", version)
+}
+
+// 数据库由更新的程序迁移过时，不执行任何迁移
+func checkNotNewer(db *gorm.DB) error {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); version > latest {
+		return fmt.Errorf("%w (database %d, program %d)", ErrSchemaTooNew, version, latest)
+	}
+	return nil
+}
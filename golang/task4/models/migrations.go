@@ -0,0 +1,336 @@
+package models
+
+import "gorm.io/gorm"
+
+// 数据库迁移，版本号递增，已发布的迁移不再修改。
+// 迁移使用固定的 SQL，不依赖当前的模型定义；版本 1 为引入迁移前的初始表结构，之后每次表结构变更追加一个版本。
+// 原先由 AutoMigrate 创建的数据库已包含部分表与字段，建表、建索引使用 IF NOT EXISTS，新增字段前先判断是否存在
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`username` text NOT NULL,`password` text NOT NULL,`avatar_url` text,`email` text NOT NULL,"+
+					"CONSTRAINT `uni_users_username` UNIQUE (`username`),CONSTRAINT `uni_users_email` UNIQUE (`email`))",
+				"CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`)",
+				"CREATE TABLE IF NOT EXISTS `posts` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`title` text NOT NULL,`content` longtext NOT NULL,`view` integer,`user_id` integer,`comment_total` integer,"+
+					"CONSTRAINT `fk_users_posts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+				"CREATE INDEX IF NOT EXISTS `idx_posts_deleted_at` ON `posts`(`deleted_at`)",
+				"CREATE TABLE IF NOT EXISTS `comments` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`content` text NOT NULL,`user_id` integer,`post_id` integer,"+
+					"CONSTRAINT `fk_comments_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`),"+
+					"CONSTRAINT `fk_posts_comments` FOREIGN KEY (`post_id`) REFERENCES `posts`(`id`))",
+				"CREATE INDEX IF NOT EXISTS `idx_comments_deleted_at` ON `comments`(`deleted_at`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "comments", "posts", "users")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "tags_and_categories",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `tags` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text NOT NULL)",
+				"CREATE INDEX IF NOT EXISTS `idx_tags_deleted_at` ON `tags`(`deleted_at`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_tags_name` ON `tags`(`name`)",
+				"CREATE TABLE IF NOT EXISTS `categories` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`name` text NOT NULL,`slug` text NOT NULL)",
+				"CREATE INDEX IF NOT EXISTS `idx_categories_deleted_at` ON `categories`(`deleted_at`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_categories_slug` ON `categories`(`slug`)",
+				"CREATE TABLE IF NOT EXISTS `post_tags` (`tag_id` integer,`post_id` integer,PRIMARY KEY (`tag_id`,`post_id`),"+
+					"CONSTRAINT `fk_post_tags_tag` FOREIGN KEY (`tag_id`) REFERENCES `tags`(`id`),"+
+					"CONSTRAINT `fk_post_tags_post` FOREIGN KEY (`post_id`) REFERENCES `posts`(`id`))",
+				"CREATE TABLE IF NOT EXISTS `post_categories` (`category_id` integer,`post_id` integer,PRIMARY KEY (`category_id`,`post_id`),"+
+					"CONSTRAINT `fk_post_categories_category` FOREIGN KEY (`category_id`) REFERENCES `categories`(`id`),"+
+					"CONSTRAINT `fk_post_categories_post` FOREIGN KEY (`post_id`) REFERENCES `posts`(`id`))",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "post_tags", "post_categories", "tags", "categories")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "post_status",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "posts", "status", "varchar(16) NOT NULL DEFAULT 'published'"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "posts", "published_at", "datetime"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"CREATE INDEX IF NOT EXISTS `idx_posts_status` ON `posts`(`status`)",
+				"CREATE INDEX IF NOT EXISTS `idx_posts_published_at` ON `posts`(`published_at`)",
+				// 补全历史文章的发布时间
+				"UPDATE `posts` SET `published_at` = `created_at` WHERE `published_at` IS NULL AND `status` = 'published'",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropSearchTriggers(tx); err != nil {
+				return err
+			}
+			return dropColumns(tx, "posts", []string{"idx_posts_status", "idx_posts_published_at"}, "status", "published_at")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "post_revisions",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `post_revisions` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`post_id` integer NOT NULL,`user_id` integer,`title` text NOT NULL,`content` longtext NOT NULL,"+
+					"CONSTRAINT `fk_post_revisions_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+				"CREATE INDEX IF NOT EXISTS `idx_post_revisions_deleted_at` ON `post_revisions`(`deleted_at`)",
+				"CREATE INDEX IF NOT EXISTS `idx_post_revisions_post_id` ON `post_revisions`(`post_id`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "post_revisions")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "comment_threads",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "comments", "parent_id", "integer"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "comments", "depth", "integer NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return execAll(tx, "CREATE INDEX IF NOT EXISTS `idx_comments_parent_id` ON `comments`(`parent_id`)")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "comments", []string{"idx_comments_parent_id"}, "parent_id", "depth")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "comment_moderation",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "comments", "status", "varchar(16) NOT NULL DEFAULT 'approved'"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "comments", "read_state", "numeric NOT NULL DEFAULT false"); err != nil {
+				return err
+			}
+			if err := addColumn(tx, "posts", "comment_approval", "varchar(16) NOT NULL DEFAULT 'trusted'"); err != nil {
+				return err
+			}
+			return execAll(tx, "CREATE INDEX IF NOT EXISTS `idx_comments_status` ON `comments`(`status`)")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropSearchTriggers(tx); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, "comments", []string{"idx_comments_status"}, "status", "read_state"); err != nil {
+				return err
+			}
+			return dropColumns(tx, "posts", nil, "comment_approval")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "user_roles",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "users", "role", "varchar(16) NOT NULL DEFAULT 'author'"); err != nil {
+				return err
+			}
+			return addColumn(tx, "users", "locked", "numeric NOT NULL DEFAULT false")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "users", nil, "role", "locked")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "login_lockout",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `login_failures` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`kind` varchar(16) NOT NULL,`key` text NOT NULL,`count` integer,`lockouts` integer,`locked_until` datetime,`last_failed_at` datetime)",
+				"CREATE INDEX IF NOT EXISTS `idx_login_failures_deleted_at` ON `login_failures`(`deleted_at`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_login_failures_key` ON `login_failures`(`kind`,`key`)",
+				"CREATE TABLE IF NOT EXISTS `lockout_events` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`kind` varchar(16) NOT NULL,`key` text NOT NULL,`failures` integer,`locked_until` datetime,`cleared_at` datetime,`cleared_by` integer)",
+				"CREATE INDEX IF NOT EXISTS `idx_lockout_events_deleted_at` ON `lockout_events`(`deleted_at`)",
+				"CREATE INDEX IF NOT EXISTS `idx_lockout_events_kind` ON `lockout_events`(`kind`)",
+				"CREATE INDEX IF NOT EXISTS `idx_lockout_events_key` ON `lockout_events`(`key`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "login_failures", "lockout_events")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "refresh_tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "users", "token_version", "integer NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `refresh_tokens` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`user_id` integer NOT NULL,`token_hash` char(64) NOT NULL,`family` varchar(32) NOT NULL,`expires_at` datetime,`revoked_at` datetime,"+
+					"`replaced_by` integer,`user_agent` text,`ip` text)",
+				"CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_deleted_at` ON `refresh_tokens`(`deleted_at`)",
+				"CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_user_id` ON `refresh_tokens`(`user_id`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_refresh_tokens_token_hash` ON `refresh_tokens`(`token_hash`)",
+				"CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_family` ON `refresh_tokens`(`family`)",
+				"CREATE TABLE IF NOT EXISTS `revoked_tokens` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`jti` varchar(64) NOT NULL,`user_id` integer,`expires_at` datetime)",
+				"CREATE INDEX IF NOT EXISTS `idx_revoked_tokens_deleted_at` ON `revoked_tokens`(`deleted_at`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_revoked_tokens_jti` ON `revoked_tokens`(`jti`)",
+				"CREATE INDEX IF NOT EXISTS `idx_revoked_tokens_user_id` ON `revoked_tokens`(`user_id`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, "refresh_tokens", "revoked_tokens"); err != nil {
+				return err
+			}
+			return dropColumns(tx, "users", nil, "token_version")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "email_verification",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "users", "email_verified_at", "datetime")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "users", nil, "email_verified_at")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "provider_identities",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `provider_identities` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`user_id` integer NOT NULL,`provider` varchar(32) NOT NULL,`subject` text NOT NULL,`login` text,`email` text)",
+				"CREATE INDEX IF NOT EXISTS `idx_provider_identities_deleted_at` ON `provider_identities`(`deleted_at`)",
+				"CREATE INDEX IF NOT EXISTS `idx_provider_identities_user_id` ON `provider_identities`(`user_id`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_provider_identities_subject` ON `provider_identities`(`provider`,`subject`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "provider_identities")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "siwe_nonces",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `siwe_nonces` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`nonce` varchar(32) NOT NULL,`expires_at` datetime,`used_at` datetime)",
+				"CREATE INDEX IF NOT EXISTS `idx_siwe_nonces_deleted_at` ON `siwe_nonces`(`deleted_at`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_siwe_nonces_nonce` ON `siwe_nonces`(`nonce`)",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "siwe_nonces")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "attachments",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `attachments` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,"+
+					"`user_id` integer,`hash` varchar(64) NOT NULL,`key` text NOT NULL,`url` text NOT NULL,`filename` text,`content_type` varchar(128),"+
+					"`size` integer,`width` integer,`height` integer,`variants` text,`detached_at` datetime,`ref_count` integer,"+
+					"CONSTRAINT `fk_attachments_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`))",
+				"CREATE INDEX IF NOT EXISTS `idx_attachments_deleted_at` ON `attachments`(`deleted_at`)",
+				"CREATE INDEX IF NOT EXISTS `idx_attachments_user_id` ON `attachments`(`user_id`)",
+				"CREATE UNIQUE INDEX IF NOT EXISTS `idx_attachments_hash` ON `attachments`(`hash`)",
+				"CREATE TABLE IF NOT EXISTS `post_attachments` (`attachment_id` integer,`post_id` integer,PRIMARY KEY (`attachment_id`,`post_id`),"+
+					"CONSTRAINT `fk_post_attachments_attachment` FOREIGN KEY (`attachment_id`) REFERENCES `attachments`(`id`),"+
+					"CONSTRAINT `fk_post_attachments_post` FOREIGN KEY (`post_id`) REFERENCES `posts`(`id`))",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "post_attachments", "attachments")
+		},
+	},
+	{
+		Version: 14,
+		Name:    "post_html",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "posts", "content_html", "longtext"); err != nil {
+				return err
+			}
+			return addColumn(tx, "posts", "toc", "text")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "posts", nil, "content_html", "toc")
+		},
+	},
+	{
+		Version: 15,
+		Name:    "post_views",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "posts", "view_total", "integer"); err != nil {
+				return err
+			}
+			return execAll(tx,
+				"CREATE TABLE IF NOT EXISTS `post_views` (`post_id` integer,`day` varchar(10),`count` integer NOT NULL DEFAULT 0,PRIMARY KEY (`post_id`,`day`))",
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropTables(tx, "post_views"); err != nil {
+				return err
+			}
+			return dropColumns(tx, "posts", nil, "view_total")
+		},
+	},
+}
+
+func execAll(tx *gorm.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 字段不存在时新增
+func addColumn(tx *gorm.DB, table, column, definition string) error {
+	if tx.Migrator().HasColumn(table, column) {
+		return nil
+	}
+	return tx.Exec("ALTER TABLE `" + table + "` ADD COLUMN `" + column + "` " + definition).Error
+}
+
+// 删除字段，先删除字段上的索引
+func dropColumns(tx *gorm.DB, table string, indexes []string, columns ...string) error {
+	for _, index := range indexes {
+		if err := tx.Exec("DROP INDEX IF EXISTS `" + index + "`").Error; err != nil {
+			return err
+		}
+	}
+	for _, column := range columns {
+		if !tx.Migrator().HasColumn(table, column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE `" + table + "` DROP COLUMN `" + column + "`").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropTables(tx *gorm.DB, tables ...string) error {
+	for _, table := range tables {
+		if err := tx.Exec("DROP TABLE IF EXISTS `" + table + "`").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"go-blog/system"
+	"html/template"
 	"log"
 	"path/filepath"
 	"time"
@@ -18,7 +20,13 @@ type User struct {
 	Password  string `gorm:"not null" json:"-"`
 	AvatarUrl string
 	Email     string `gorm:"unique;not null"`
-	Posts     []Post `gorm:"foreignKey:UserID"` // 一对多关联
+	Posts     []Post `gorm:"foreignKey:UserID"`                        // 一对多关联
+	Role      string `gorm:"type:varchar(16);not null;default:author"` // admin/editor/author/visitor
+	Locked    bool   `gorm:"not null;default:false"`                   // 锁定的用户无法登录
+	// 访问令牌版本，退出所有设备时递增，旧版本的访问令牌全部失效
+	TokenVersion int `gorm:"not null;default:0" json:"-"`
+	// 邮箱验证时间，未验证时为空
+	EmailVerifiedAt *time.Time
 }
 
 func (User) TableName() string {
@@ -33,8 +41,19 @@ type Post struct {
 	View         int    // view count
 	UserID       uint
 	User         User
-	Comments     []Comment `gorm:"foreignKey:PostID"`
-	CommentTotal int       `gorm:"->"` // count of comment
+	Comments     []Comment    `gorm:"foreignKey:PostID"`
+	CommentTotal int          `gorm:"->"` // count of comment
+	ViewTotal    int          `gorm:"->"` // 阅读排行中统计时间段内的阅读数
+	Tags         []Tag        `gorm:"many2many:post_tags"`
+	Categories   []Category   `gorm:"many2many:post_categories"`
+	Attachments  []Attachment `gorm:"many2many:post_attachments"`
+	Status       string       `gorm:"type:varchar(16);not null;default:published;index"` // draft/published/scheduled
+	PublishedAt  *time.Time   `gorm:"index"`                                             // 发布时间，定时发布时为计划时间
+	// 评论审核规则：manual/trusted/auto
+	CommentApproval string `gorm:"type:varchar(16);not null;default:trusted"`
+	// 渲染后的 HTML 与目录，内容修改时清空，读取时重新渲染
+	ContentHTML string `gorm:"type:longtext"`
+	TOC         string `gorm:"type:text"`
 }
 
 func (Post) TableName() string {
@@ -48,6 +67,13 @@ type Comment struct {
 	User    User
 	PostID  uint
 	Post    Post
+	// 回复的上级评论，顶级评论为空
+	ParentID *uint      `gorm:"index"`
+	Depth    int        `gorm:"not null;default:0"` // 嵌套层级，顶级评论为 0
+	Children []*Comment `gorm:"-"`
+	// 审核状态：pending/approved/spam/rejected
+	Status    string `gorm:"type:varchar(16);not null;default:approved;index"`
+	ReadState bool   `gorm:"not null;default:false"` // 作者是否已读
 }
 
 func (Comment) TableName() string {
@@ -75,23 +101,49 @@ func GetDBPath(dbName string) string {
 	return filepath.Join(testDir, ".", "db", dbName)
 }
 
+// OpenDB 打开数据库，不执行迁移
+func OpenDB() (*gorm.DB, error) {
+	cfg := system.GetConfiguration()
+	db, err := gorm.Open(sqlite.Open(GetDBPath(cfg.Database.DSN)), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	DB = db
+	return db, nil
+}
+
 func InitDB() (*gorm.DB, error) {
-	var (
-		db  *gorm.DB
-		err error
-		cfg = system.GetConfiguration()
-	)
-	db, err = gorm.Open(sqlite.Open(GetDBPath(cfg.Database.DSN)), &gorm.Config{})
+	db, err := OpenDB()
 	if err != nil {
 		return nil, err
 	}
 
-	DB = db
+	// 内容变更事件，用于清除页面缓存
+	if err = RegisterChangeCallbacks(db); err != nil {
+		return nil, err
+	}
 
-	// 自动迁移模型
-	db.AutoMigrate(&User{}, &Post{}, &Comment{})
+	// 数据库版本检查：高于程序时拒绝启动；有未执行的迁移时按配置自动执行或拒绝启动
+	if err = CheckSchema(db); errors.Is(err, ErrSchemaOutdated) && system.GetConfiguration().Database.AutoMigrate {
+		var executed []Migration
+		executed, err = MigrateUp(db)
+		for _, m := range executed {
+			log.Printf("migration %d_%s applied", m.Version, m.Name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 保证至少存在一个管理员
+	ensureAdmin()
+
+	// 全文检索索引
+	if err := InitSearch(db); err != nil {
+		log.Println("full-text search disabled, fallback to LIKE:", err)
+	}
 
-	return db, err
+	return db, nil
 }
 
 // Post
@@ -100,18 +152,40 @@ func (post *Post) Insert() error {
 }
 
 func (post *Post) Update() error {
+	post.ContentHTML, post.TOC = "", ""
 	return DB.Model(post).Updates(map[string]interface{}{
-		"title":      post.Title,
-		"content":    post.Content,
-		"updated_at": time.Now(),
+		"title":        post.Title,
+		"content":      post.Content,
+		"content_html": "",
+		"toc":          "",
+		"updated_at":   time.Now(),
 	}).Error
 }
 
+// 保存渲染结果，不修改更新时间
+func (post *Post) SaveRendered() error {
+	return DB.Model(post).UpdateColumns(map[string]interface{}{
+		"content_html": post.ContentHTML,
+		"toc":          post.TOC,
+	}).Error
+}
+
+// HTML 渲染后的文章内容，已经过白名单过滤
+func (post *Post) HTML() template.HTML {
+	return template.HTML(post.ContentHTML)
+}
+
 func (post *Post) Delete() error {
+	if err := detachPostAttachments(DB, post.ID); err != nil {
+		return err
+	}
 	return DB.Delete(post).Error
 }
 
 func (post *Post) LogicDelete() error {
+	if err := detachPostAttachments(DB, post.ID); err != nil {
+		return err
+	}
 	return DB.Model(post).Updates(map[string]interface{}{
 		"deleted_at": time.Now(),
 	}).Error
@@ -121,7 +195,7 @@ func ListMaxCommentPost() (posts []*Post, err error) {
 	var (
 		rows *sql.Rows
 	)
-	rows, err = DB.Raw("select p.*,c.total comment_total from posts p inner join (select post_id,count(*) total from comments group by post_id) c on p.id = c.post_id order by c.total desc limit 5").Rows()
+	rows, err = DB.Raw("select p.*,c.total comment_total from posts p inner join (select post_id,count(*) total from comments where deleted_at is null and status = ? group by post_id) c on p.id = c.post_id where p.deleted_at is null and p.status = ? order by c.total desc limit 5", CommentStatusApproved, PostStatusPublished).Rows()
 	if err != nil {
 		return
 	}
@@ -134,28 +208,47 @@ func ListMaxCommentPost() (posts []*Post, err error) {
 	return
 }
 
+// 总阅读数最多的文章
 func ListMaxReadPost() (posts []*Post, err error) {
-	err = DB.Order("updated_at desc").Limit(5).Find(&posts).Error
-	return
+	return ListMostReadPost(0, 5)
 }
 
 func ListAllPost() ([]*Post, error) {
 	return _listPost(0, 0)
 }
 
+// 分页查询文章，pageIndex 从 1 开始
+func ListPost(pageIndex, pageSize int) ([]*Post, error) {
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+	return _listPost(pageIndex, pageSize)
+}
+
 func _listPost(pageIndex, pageSize int) ([]*Post, error) {
 	var posts []*Post
 	var err error
 	if pageIndex > 0 {
-		err = DB.Order("created_at desc").Limit(pageSize).Offset((pageIndex - 1) * pageSize).Find(&posts).Error
+		err = DB.Preload("Tags").Preload("Categories").Scopes(Published).Order("published_at desc, id desc").Limit(pageSize).Offset((pageIndex - 1) * pageSize).Find(&posts).Error
 	} else {
-		err = DB.Order("created_at desc").Find(&posts).Error
+		err = DB.Preload("Tags").Preload("Categories").Scopes(Published).Order("published_at desc, id desc").Find(&posts).Error
+	}
+	return posts, err
+}
+
+// 游标分页：返回 ID 小于 afterID 的文章，afterID 为 0 时从最新一篇开始
+func ListPostAfter(afterID uint, size int) ([]*Post, error) {
+	var posts []*Post
+	db := DB.Preload("Tags").Preload("Categories").Scopes(Published).Order("id desc").Limit(size)
+	if afterID > 0 {
+		db = db.Where("id < ?", afterID)
 	}
+	err := db.Find(&posts).Error
 	return posts, err
 }
 
 func CountPost() (count int, err error) {
-	err = DB.Raw("select count(*) from posts p").Row().Scan(&count)
+	err = DB.Raw("select count(*) from posts p where p.deleted_at is null and p.status = ?", PostStatusPublished).Row().Scan(&count)
 	return
 }
 
@@ -163,8 +256,8 @@ func ListPostArchives() ([]*QrArchive, error) {
 	var (
 		archives []*QrArchive
 	)
-	querySql := `select strftime('%Y-%m',created_at) as month,count(*) as total from posts group by month order by month desc`
-	rows, err := DB.Raw(querySql).Rows()
+	querySql := `select strftime('%Y-%m',published_at) as month,count(*) as total from posts where deleted_at is null and status = ? group by month order by month desc`
+	rows, err := DB.Raw(querySql, PostStatusPublished).Rows()
 	if err != nil {
 		return nil, err
 	}
@@ -190,8 +283,10 @@ func (user *User) Insert() error {
 
 // 登录请求体
 type LoginRequest struct {
-	Username string `form:"username" json:"username" binding:"required"`
-	Password string `form:"password" json:"password" binding:"required"`
+	Username   string `form:"username" json:"username" binding:"required"`
+	Password   string `form:"password" json:"password" binding:"required"`
+	CaptchaId  string `form:"captchaId" json:"captchaId"`   // 连续登录失败后需要
+	VerifyCode string `form:"verifyCode" json:"verifyCode"` // 连续登录失败后需要
 }
 
 // 注册
@@ -213,14 +308,41 @@ func GetUser(id interface{}) (*User, error) {
 	return &user, err
 }
 
+func GetUserByEmail(email string) (*User, error) {
+	var user User
+	err := DB.First(&user, "email = ?", email).Error
+	return &user, err
+}
+
+// 更换邮箱后需重新验证
 func (user *User) UpdateEmail(email string) error {
 	if len(email) > 0 {
-		return DB.Model(user).Update("email", email).Error
+		return DB.Model(user).Updates(map[string]interface{}{"email": email, "email_verified_at": nil}).Error
 	} else {
 		return DB.Model(user).Update("email", gorm.Expr("NULL")).Error
 	}
 }
 
+// 标记邮箱已验证
+func (user *User) VerifyEmail() error {
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return DB.Model(user).UpdateColumn("email_verified_at", now).Error
+}
+
+func (user *User) IsEmailVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+// 重置密码，并使已签发的令牌全部失效
+func (user *User) UpdatePassword(hashedPassword string) error {
+	if err := DB.Model(user).UpdateColumn("password", hashedPassword).Error; err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	return user.LogoutEverywhere()
+}
+
 // Comment
 func (comment *Comment) Insert() error {
 	return DB.Create(comment).Error
@@ -230,6 +352,16 @@ func (comment *Comment) Update() error {
 	return DB.Model(comment).UpdateColumn("read_state", true).Error
 }
 
+// UpdateContent 保存编辑后的内容与重新审核的状态
+func (comment *Comment) UpdateContent() error {
+	return DB.Model(comment).Updates(map[string]interface{}{
+		"content":    comment.Content,
+		"status":     comment.Status,
+		"read_state": comment.ReadState,
+		"updated_at": time.Now(),
+	}).Error
+}
+
 func (comment *Comment) Delete() error {
 	return DB.Delete(comment, "user_id = ?", comment.UserID).Error
 }
@@ -268,12 +400,20 @@ func ListCommentByPostID(id uint) ([]Comment, error) {
 	var comments []Comment
 	err := DB.Preload("User").
 		Where("post_id = ?", id).
+		Scopes(Approved).
+		Order("created_at, id").
 		Find(&comments).Error
 	return comments, err
 }
 
+func GetCommentById(id uint) (*Comment, error) {
+	var comment Comment
+	err := DB.Preload("User").First(&comment, "id = ?", id).Error
+	return &comment, err
+}
+
 func GetPostById(id uint) (*Post, error) {
 	var post Post
-	err := DB.Where("deleted_at IS NULL").First(&post, "id = ?", id).Error
+	err := DB.Preload("User").Preload("Tags").Preload("Categories").Scopes(Published).First(&post, "id = ?", id).Error
 	return &post, err
 }
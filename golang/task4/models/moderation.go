@@ -0,0 +1,157 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 评论审核状态
+const (
+	CommentStatusPending  = "pending"  // 待审核
+	CommentStatusApproved = "approved" // 已通过
+	CommentStatusSpam     = "spam"     // 垃圾评论
+	CommentStatusRejected = "rejected" // 已拒绝
+)
+
+// 文章评论审核规则
+const (
+	CommentApprovalManual  = "manual"  // 全部评论人工审核
+	CommentApprovalTrusted = "trusted" // 受信任的评论者自动通过，其余人工审核
+	CommentApprovalAuto    = "auto"    // 全部评论自动通过
+)
+
+var (
+	ErrCommentStatusInvalid   = errors.New("comment status invalid")
+	ErrCommentApprovalInvalid = errors.New("comment approval rule invalid")
+)
+
+func IsCommentStatus(status string) bool {
+	switch status {
+	case CommentStatusPending, CommentStatusApproved, CommentStatusSpam, CommentStatusRejected:
+		return true
+	}
+	return false
+}
+
+func IsCommentApproval(approval string) bool {
+	switch approval {
+	case CommentApprovalManual, CommentApprovalTrusted, CommentApprovalAuto:
+		return true
+	}
+	return false
+}
+
+// 公开查询只返回审核通过的评论
+func Approved(db *gorm.DB) *gorm.DB {
+	return db.Where("comments.status = ?", CommentStatusApproved)
+}
+
+// IsTrustedCommenter 文章作者以及已有评论通过审核的用户视为受信任的评论者
+func IsTrustedCommenter(post *Post, userID uint) bool {
+	return isTrustedCommenter(post, userID, 0)
+}
+
+// 不计入 excludeID 对应的评论，避免编辑后的评论因自身已通过审核而被信任
+func isTrustedCommenter(post *Post, userID, excludeID uint) bool {
+	if userID == 0 {
+		return false
+	}
+	if post.UserID == userID {
+		return true
+	}
+	var count int64
+	DB.Model(&Comment{}).Where("user_id = ? and status = ? and id <> ?", userID, CommentStatusApproved, excludeID).Count(&count)
+	return count > 0
+}
+
+// Moderate 根据文章的评论审核规则设置新评论或编辑后评论的状态，文章作者自己的评论直接标记为已读
+func (comment *Comment) Moderate(post *Post) {
+	comment.Status = CommentStatusPending
+	switch post.CommentApproval {
+	case CommentApprovalAuto:
+		comment.Status = CommentStatusApproved
+	case CommentApprovalManual:
+	default:
+		if isTrustedCommenter(post, comment.UserID, comment.ID) {
+			comment.Status = CommentStatusApproved
+		}
+	}
+	comment.ReadState = post.UserID == comment.UserID
+}
+
+func (comment *Comment) IsApproved() bool {
+	return comment.Status == CommentStatusApproved
+}
+
+func (post *Post) UpdateCommentApproval() error {
+	return DB.Model(post).UpdateColumn("comment_approval", post.CommentApproval).Error
+}
+
+// 用户可审核的评论：具备 comment.moderate_any 权限时为全部评论，否则为自己文章下的评论；
+// status 为空时查询全部状态
+func moderationScope(user *User, status string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !user.Can(PermCommentModerateAny) {
+			db = db.Where("comments.post_id in (select id from posts where user_id = ? and deleted_at is null)", user.ID)
+		}
+		if len(status) > 0 {
+			db = db.Where("comments.status = ?", status)
+		}
+		return db
+	}
+}
+
+// ListModerationComment 审核列表，按时间倒序
+func ListModerationComment(user *User, status string, pageIndex, pageSize int) ([]*Comment, error) {
+	var comments []*Comment
+	db := DB.Preload("User").Preload("Post").
+		Scopes(moderationScope(user, status)).
+		Order("comments.id desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&comments).Error
+	return comments, err
+}
+
+func CountModerationComment(user *User, status string) (count int64, err error) {
+	err = DB.Model(&Comment{}).Scopes(moderationScope(user, status)).Count(&count).Error
+	return
+}
+
+// UpdateCommentStatus 批量修改用户可审核评论的审核状态，同时标记为已读，返回修改数量
+func UpdateCommentStatus(user *User, ids []uint, status string) (int64, error) {
+	if !IsCommentStatus(status) {
+		return 0, ErrCommentStatusInvalid
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := DB.Model(&Comment{}).
+		Scopes(moderationScope(user, "")).
+		Where("comments.id in ?", ids).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"read_state": true,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// ListUnreadComment 用户可审核的未读评论
+func ListUnreadComment(user *User) ([]*Comment, error) {
+	var comments []*Comment
+	err := DB.Scopes(moderationScope(user, "")).
+		Where("comments.read_state = ?", false).
+		Order("comments.id desc").
+		Find(&comments).Error
+	return comments, err
+}
+
+// ReadAllComment 将用户可审核的评论全部标记为已读
+func ReadAllComment(user *User) error {
+	return DB.Model(&Comment{}).
+		Scopes(moderationScope(user, "")).
+		Where("comments.read_state = ?", false).
+		UpdateColumn("read_state", true).Error
+}
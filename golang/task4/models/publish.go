@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文章状态
+const (
+	PostStatusDraft     = "draft"     // 草稿
+	PostStatusPublished = "published" // 已发布
+	PostStatusScheduled = "scheduled" // 定时发布
+)
+
+var ErrPublishTimeRequired = errors.New("publish time is required for scheduled post")
+
+// 新建文章默认为已发布，发布时间取当前时间
+func (post *Post) BeforeCreate(tx *gorm.DB) error {
+	if len(post.Status) == 0 {
+		post.Status = PostStatusPublished
+	}
+	if post.Status == PostStatusPublished && post.PublishedAt == nil {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+	return nil
+}
+
+// 公开查询只返回已发布的文章
+func Published(db *gorm.DB) *gorm.DB {
+	return db.Where("posts.status = ?", PostStatusPublished)
+}
+
+func (post *Post) IsPublished() bool {
+	return post.Status == PostStatusPublished
+}
+
+// SetStatus 设置文章状态；定时发布的时间已过时直接发布。
+// status 为空时新文章直接发布，已有文章保留当前状态，定时发布的文章可只修改发布时间
+func (post *Post) SetStatus(status string, publishAt *time.Time) error {
+	if len(status) == 0 && len(post.Status) > 0 {
+		if post.Status != PostStatusScheduled || publishAt == nil {
+			// 补全历史文章的发布时间
+			if post.Status == PostStatusPublished && post.PublishedAt == nil {
+				publishedAt := post.CreatedAt
+				post.PublishedAt = &publishedAt
+			}
+			return nil
+		}
+		status = PostStatusScheduled
+	}
+	now := time.Now()
+	switch status {
+	case "", PostStatusPublished:
+		post.Status = PostStatusPublished
+		if post.PublishedAt == nil || post.PublishedAt.After(now) {
+			post.PublishedAt = &now
+		}
+	case PostStatusDraft:
+		post.Status = PostStatusDraft
+		post.PublishedAt = nil
+	case PostStatusScheduled:
+		if publishAt == nil {
+			return ErrPublishTimeRequired
+		}
+		post.Status = PostStatusScheduled
+		if !publishAt.After(now) {
+			post.Status = PostStatusPublished
+		}
+		post.PublishedAt = publishAt
+	default:
+		return errors.New("post status invalid: " + status)
+	}
+	return nil
+}
+
+func (post *Post) UpdateStatus() error {
+	return DB.Model(post).Updates(map[string]interface{}{
+		"status":       post.Status,
+		"published_at": post.PublishedAt,
+		"updated_at":   time.Now(),
+	}).Error
+}
+
+// 立即发布
+func (post *Post) Publish() error {
+	if err := post.SetStatus(PostStatusPublished, nil); err != nil {
+		return err
+	}
+	return post.UpdateStatus()
+}
+
+// PublishDuePosts 将发布时间已到的定时文章切换为已发布，返回发布数量
+func PublishDuePosts(now time.Time) (int64, error) {
+	result := DB.Model(&Post{}).
+		Where("status = ? and published_at <= ?", PostStatusScheduled, now).
+		Updates(map[string]interface{}{
+			"status":     PostStatusPublished,
+			"updated_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// NextScheduledAt 返回最近一篇待发布文章的发布时间，没有时返回 nil
+func NextScheduledAt() (*time.Time, error) {
+	var post Post
+	err := DB.Select("published_at").
+		Where("status = ?", PostStatusScheduled).
+		Order("published_at").
+		Limit(1).Find(&post).Error
+	return post.PublishedAt, err
+}
+
+// 根据 ID 查询文章（包含草稿与定时发布），用于作者编辑
+func GetAnyPostById(id uint) (*Post, error) {
+	var post Post
+	err := DB.Preload("User").Preload("Tags").Preload("Categories").First(&post, "id = ?", id).Error
+	return &post, err
+}
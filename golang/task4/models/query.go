@@ -0,0 +1,90 @@
+package models
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 文章筛选条件
+type PostQuery struct {
+	UserID   uint   `form:"user_id"`
+	Tag      string `form:"tag"`
+	Category string `form:"category"` // 分类 slug
+	Keyword  string `form:"keyword"`  // 标题模糊匹配
+	Status   string `form:"-"`        // 为空时只查询已发布的文章，"all" 查询全部
+}
+
+// 查询全部状态的文章
+const PostStatusAll = "all"
+
+func (query PostQuery) scope(db *gorm.DB) *gorm.DB {
+	switch query.Status {
+	case "":
+		db = db.Scopes(Published)
+	case PostStatusAll:
+	default:
+		db = db.Where("posts.status = ?", query.Status)
+	}
+	if query.UserID > 0 {
+		db = db.Where("posts.user_id = ?", query.UserID)
+	}
+	if len(query.Tag) > 0 {
+		db = db.Where("exists (select 1 from post_tags pt inner join tags t on t.id = pt.tag_id where pt.post_id = posts.id and t.name = ?)", query.Tag)
+	}
+	if len(query.Category) > 0 {
+		db = db.Where("exists (select 1 from post_categories pc inner join categories c on c.id = pc.category_id where pc.post_id = posts.id and c.slug = ?)", query.Category)
+	}
+	if keyword := strings.TrimSpace(query.Keyword); len(keyword) > 0 {
+		db = db.Where("posts.title like ?", "%"+keyword+"%")
+	}
+	return db
+}
+
+func ListPostByQuery(query PostQuery, pageIndex, pageSize int) ([]*Post, error) {
+	var posts []*Post
+	db := DB.Preload("User").Preload("Tags").Preload("Categories").
+		Scopes(query.scope).
+		Order("posts.published_at is null desc, posts.published_at desc, posts.id desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&posts).Error
+	return posts, err
+}
+
+func CountPostByQuery(query PostQuery) (count int64, err error) {
+	err = DB.Model(&Post{}).Scopes(query.scope).Count(&count).Error
+	return
+}
+
+// 用户筛选条件
+type UserQuery struct {
+	Username string `form:"username"` // 用户名模糊匹配
+	Email    string `form:"email"`
+}
+
+func (query UserQuery) scope(db *gorm.DB) *gorm.DB {
+	if username := strings.TrimSpace(query.Username); len(username) > 0 {
+		db = db.Where("username like ?", "%"+username+"%")
+	}
+	if len(query.Email) > 0 {
+		db = db.Where("email = ?", query.Email)
+	}
+	return db
+}
+
+func ListUser(query UserQuery, pageIndex, pageSize int) ([]*User, error) {
+	var users []*User
+	db := DB.Scopes(query.scope).Order("id")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&users).Error
+	return users, err
+}
+
+func CountUser(query UserQuery) (count int64, err error) {
+	err = DB.Model(&User{}).Scopes(query.scope).Count(&count).Error
+	return
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // 基础响应结构体
 type BaseResponse struct {
 	Code int    `json:"code"` // 状态码
@@ -23,8 +25,149 @@ type PageResponse[T any] struct {
 
 // 登录成功返回数据体
 type LoginData struct {
-	Token     string `json:"token"`      // JWT
-	ExpiresAt int64  `json:"expires_at"` // 过期时间（时间戳）
-	UserID    uint   `json:"user_id"`    // 用户ID
-	Username  string `json:"username"`   // 用户名
+	Token            string `json:"token"`              // JWT 访问令牌
+	ExpiresAt        int64  `json:"expires_at"`         // 过期时间（时间戳）
+	RefreshToken     string `json:"refresh_token"`      // 刷新令牌，每次使用后轮换
+	RefreshExpiresAt int64  `json:"refresh_expires_at"` // 刷新令牌过期时间（时间戳）
+	UserID           uint   `json:"user_id"`            // 用户ID
+	Username         string `json:"username"`           // 用户名
+}
+
+// 游标分页
+type CursorResponse[T any] struct {
+	BaseResponse
+	Payload    []T    `json:"payload"`     // 列表数据
+	NextCursor string `json:"next_cursor"` // 下一页游标，为空表示没有更多数据
+	Size       int64  `json:"size"`        // 每页条数
+}
+
+// API 用户数据
+type UserData struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	AvatarUrl string    `json:"avatar_url"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// API 文章数据
+type PostData struct {
+	ID              uint       `json:"id"`
+	Title           string     `json:"title"`
+	Content         string     `json:"content"`
+	View            int        `json:"view"`
+	Author          UserData   `json:"author"`
+	Tags            []string   `json:"tags"`
+	Categories      []string   `json:"categories"`
+	CommentTotal    int        `json:"comment_total"`
+	Status          string     `json:"status"`
+	PublishedAt     *time.Time `json:"published_at"`
+	CommentApproval string     `json:"comment_approval"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// API 评论数据
+type CommentData struct {
+	ID        uint          `json:"id"`
+	PostID    uint          `json:"post_id"`
+	ParentID  *uint         `json:"parent_id"`
+	Depth     int           `json:"depth"`
+	Status    string        `json:"status"`
+	Content   string        `json:"content"`
+	Author    UserData      `json:"author"`
+	Replies   []CommentData `json:"replies"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// API 附件数据
+type AttachmentData struct {
+	ID          uint                `json:"id"`
+	URL         string              `json:"url"`
+	Filename    string              `json:"filename"`
+	ContentType string              `json:"content_type"`
+	Size        int64               `json:"size"`
+	Hash        string              `json:"hash"`
+	Width       int                 `json:"width,omitempty"`
+	Height      int                 `json:"height,omitempty"`
+	Variants    []AttachmentVariant `json:"variants"`
+	RefCount    int                 `json:"ref_count"`
+	Uploader    UserData            `json:"uploader"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+func NewUserData(user *User) UserData {
+	return UserData{
+		ID:        user.ID,
+		Username:  user.Username,
+		AvatarUrl: user.AvatarUrl,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func NewPostData(post *Post) PostData {
+	data := PostData{
+		ID:              post.ID,
+		Title:           post.Title,
+		Content:         post.Content,
+		View:            post.View,
+		Author:          NewUserData(&post.User),
+		Tags:            []string{},
+		Categories:      []string{},
+		CommentTotal:    post.CommentTotal,
+		Status:          post.Status,
+		PublishedAt:     post.PublishedAt,
+		CommentApproval: post.CommentApproval,
+		CreatedAt:       post.CreatedAt,
+		UpdatedAt:       post.UpdatedAt,
+	}
+	for _, tag := range post.Tags {
+		data.Tags = append(data.Tags, tag.Name)
+	}
+	for _, category := range post.Categories {
+		data.Categories = append(data.Categories, category.Slug)
+	}
+	return data
+}
+
+func NewCommentData(comment *Comment) CommentData {
+	data := CommentData{
+		ID:        comment.ID,
+		PostID:    comment.PostID,
+		ParentID:  comment.ParentID,
+		Depth:     comment.Depth,
+		Status:    comment.Status,
+		Content:   comment.Content,
+		Author:    NewUserData(&comment.User),
+		Replies:   make([]CommentData, 0, len(comment.Children)),
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+	}
+	for _, child := range comment.Children {
+		data.Replies = append(data.Replies, NewCommentData(child))
+	}
+	return data
+}
+
+func NewAttachmentData(a *Attachment) AttachmentData {
+	variants := a.VariantList()
+	if variants == nil {
+		variants = []AttachmentVariant{}
+	}
+	return AttachmentData{
+		ID:          a.ID,
+		URL:         a.URL,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Hash:        a.Hash,
+		Width:       a.Width,
+		Height:      a.Height,
+		Variants:    variants,
+		RefCount:    a.RefCount,
+		Uploader:    NewUserData(&a.User),
+		CreatedAt:   a.CreatedAt,
+	}
 }
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文章修订记录，每次保存文章时记录完整的标题与内容
+type PostRevision struct {
+	gorm.Model
+	PostID  uint   `gorm:"index;not null"`
+	UserID  uint   // 修改人
+	User    User   `gorm:"foreignKey:UserID"`
+	Title   string `gorm:"not null"`
+	Content string `gorm:"type:longtext;not null"`
+}
+
+func (PostRevision) TableName() string {
+	return "post_revisions"
+}
+
+// 将文章当前的标题与内容保存为一条修订记录
+func (post *Post) SaveRevision(userID uint) error {
+	return saveRevision(DB, post, userID)
+}
+
+// 每次保存文章内容时调用，同时更新文章引用的附件
+func saveRevision(db *gorm.DB, post *Post, userID uint) error {
+	err := db.Create(&PostRevision{
+		PostID:  post.ID,
+		UserID:  userID,
+		Title:   post.Title,
+		Content: post.Content,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return syncAttachments(db, post)
+}
+
+// 历史文章没有修订记录时，先以当前内容作为初始版本，避免首次修改丢失原文
+func (post *Post) EnsureRevision() error {
+	var count int64
+	if err := DB.Model(&PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return saveRevision(DB, post, post.UserID)
+}
+
+// 恢复到指定修订版本，恢复操作本身也会产生一条新的修订记录
+func (post *Post) RestoreRevision(revision *PostRevision, userID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		post.Title = revision.Title
+		post.Content = revision.Content
+		post.ContentHTML, post.TOC = "", ""
+		err := tx.Model(post).Updates(map[string]interface{}{
+			"title":        post.Title,
+			"content":      post.Content,
+			"content_html": "",
+			"toc":          "",
+			"updated_at":   time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return saveRevision(tx, post, userID)
+	})
+}
+
+// 文章的修订记录，按时间倒序
+func ListRevisionByPostID(postID uint) ([]*PostRevision, error) {
+	var revisions []*PostRevision
+	err := DB.Preload("User").Where("post_id = ?", postID).Order("id desc").Find(&revisions).Error
+	return revisions, err
+}
+
+func GetRevisionById(id uint) (*PostRevision, error) {
+	var revision PostRevision
+	err := DB.Preload("User").First(&revision, "id = ?", id).Error
+	return &revision, err
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"go-blog/system"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 用户角色
+const (
+	RoleAdmin   = "admin"   // 管理员：全部权限
+	RoleEditor  = "editor"  // 编辑：管理所有文章与评论
+	RoleAuthor  = "author"  // 作者：管理自己的文章及其评论
+	RoleVisitor = "visitor" // 访客：只能发表评论
+)
+
+// 权限
+type Permission string
+
+const (
+	PermAdminAccess        Permission = "admin.access"         // 进入后台
+	PermPostCreate         Permission = "post.create"          // 新建、编辑自己的文章
+	PermPostEditAny        Permission = "post.edit_any"        // 编辑、发布、删除任意文章
+	PermCommentCreate      Permission = "comment.create"       // 发表评论
+	PermCommentModerateAny Permission = "comment.moderate_any" // 审核任意文章的评论
+	PermUserManage         Permission = "user.manage"          // 用户管理
+)
+
+// 角色列表，按权限从高到低排列
+var Roles = []string{RoleAdmin, RoleEditor, RoleAuthor, RoleVisitor}
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermAdminAccess, PermPostCreate, PermPostEditAny,
+		PermCommentCreate, PermCommentModerateAny, PermUserManage,
+	},
+	RoleEditor: {
+		PermAdminAccess, PermPostCreate, PermPostEditAny,
+		PermCommentCreate, PermCommentModerateAny,
+	},
+	RoleAuthor: {
+		PermAdminAccess, PermPostCreate, PermCommentCreate,
+	},
+	RoleVisitor: {
+		PermCommentCreate,
+	},
+}
+
+var (
+	ErrRoleInvalid = errors.New("role invalid")
+	ErrUserLocked  = errors.New("user is locked")
+	ErrModifySelf  = errors.New("cannot change role or lock state of yourself")
+	ErrLastAdmin   = errors.New("at least one unlocked admin is required")
+)
+
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// 新用户默认角色：系统中的第一个用户为管理员，其余取配置中的默认角色
+func (user *User) BeforeCreate(tx *gorm.DB) error {
+	if len(user.Role) > 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	user.Role = DefaultRole()
+	if count == 0 {
+		user.Role = RoleAdmin
+	}
+	return nil
+}
+
+// DefaultRole 新注册用户的角色，取配置文件中的 default_role
+func DefaultRole() string {
+	if cfg := system.GetConfiguration(); cfg != nil && IsRole(cfg.DefaultRole) {
+		return cfg.DefaultRole
+	}
+	return RoleAuthor
+}
+
+// Can 判断用户是否具备指定权限，锁定的用户不具备任何权限
+func (user *User) Can(perm Permission) bool {
+	if user == nil || user.Locked {
+		return false
+	}
+	for _, p := range rolePermissions[user.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanEditPost 文章作者或具备 post.edit_any 权限的用户才能修改文章
+func (user *User) CanEditPost(post *Post) bool {
+	if user.Can(PermPostEditAny) {
+		return true
+	}
+	return user.Can(PermPostCreate) && post.UserID == user.ID
+}
+
+// CanModerateComment 文章作者或具备 comment.moderate_any 权限的用户才能审核文章的评论
+func (user *User) CanModerateComment(post *Post) bool {
+	if user.Can(PermCommentModerateAny) {
+		return true
+	}
+	return user.Can(PermAdminAccess) && post.UserID == user.ID
+}
+
+// UpdateRole 修改用户角色，不能取消最后一个管理员
+func (user *User) UpdateRole(role string) error {
+	if !IsRole(role) {
+		return ErrRoleInvalid
+	}
+	if user.Role == RoleAdmin && role != RoleAdmin && countActiveAdmin() <= 1 {
+		return ErrLastAdmin
+	}
+	user.Role = role
+	return DB.Model(user).UpdateColumn("role", role).Error
+}
+
+// UpdateLocked 锁定、解锁用户，锁定的用户无法登录
+func (user *User) UpdateLocked(locked bool) error {
+	if locked && user.Role == RoleAdmin && !user.Locked && countActiveAdmin() <= 1 {
+		return ErrLastAdmin
+	}
+	user.Locked = locked
+	return DB.Model(user).UpdateColumn("locked", locked).Error
+}
+
+func countActiveAdmin() int64 {
+	var count int64
+	DB.Model(&User{}).Where("role = ? and locked = ?", RoleAdmin, false).Count(&count)
+	return count
+}
+
+// 不存在管理员时，将最早注册的用户设置为管理员
+func ensureAdmin() {
+	if countActiveAdmin() > 0 {
+		return
+	}
+	var user User
+	if err := DB.Where("locked = ?", false).Order("id").Limit(1).Find(&user).Error; err != nil || user.ID == 0 {
+		return
+	}
+	if err := DB.Model(&user).UpdateColumn("role", RoleAdmin).Error; err == nil {
+		log.Printf("user %s promoted to admin", user.Username)
+	}
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 高亮标记，由调用方转义正文后替换为 <mark> 标签
+const (
+	HighlightOpen  = "\x02"
+	HighlightClose = "\x03"
+)
+
+// 全文检索结果
+type SearchResult struct {
+	PostID    uint      `json:"post_id"`
+	Title     string    `json:"title"`   // 含高亮标记的标题
+	Snippet   string    `json:"snippet"` // 含高亮标记的正文片段
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 是否启用了 FTS5（需以 -tags sqlite_fts5 编译）
+var ftsEnabled bool
+
+// posts_fts 以 posts.id 作为 rowid，通过触发器与 posts、comments 保持同步；
+// 更新触发器只关注检索相关的列，浏览数等频繁写入不会重建索引行
+var ftsStatements = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS posts_fts USING fts5(title, content, comments, tokenize = 'trigram')`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_ai AFTER INSERT ON posts WHEN new.deleted_at IS NULL BEGIN
+		INSERT INTO posts_fts(rowid, title, content, comments) VALUES (new.id, new.title, new.content, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_au AFTER UPDATE OF title, content, deleted_at, status ON posts BEGIN
+		DELETE FROM posts_fts WHERE rowid = old.id;
+		INSERT INTO posts_fts(rowid, title, content, comments)
+			SELECT new.id, new.title, new.content,
+				coalesce((SELECT group_concat(content, ' ') FROM comments WHERE post_id = new.id AND deleted_at IS NULL AND status = 'approved'), '')
+			WHERE new.deleted_at IS NULL;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS posts_fts_ad AFTER DELETE ON posts BEGIN
+		DELETE FROM posts_fts WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_ai AFTER INSERT ON comments BEGIN
+		UPDATE posts_fts SET comments = coalesce((SELECT group_concat(content, ' ') FROM comments WHERE post_id = new.post_id AND deleted_at IS NULL AND status = 'approved'), '')
+			WHERE rowid = new.post_id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_au AFTER UPDATE OF content, deleted_at, status ON comments BEGIN
+		UPDATE posts_fts SET comments = coalesce((SELECT group_concat(content, ' ') FROM comments WHERE post_id = new.post_id AND deleted_at IS NULL AND status = 'approved'), '')
+			WHERE rowid = new.post_id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS comments_fts_ad AFTER DELETE ON comments BEGIN
+		UPDATE posts_fts SET comments = coalesce((SELECT group_concat(content, ' ') FROM comments WHERE post_id = old.post_id AND deleted_at IS NULL AND status = 'approved'), '')
+			WHERE rowid = old.post_id;
+	END`,
+}
+
+var ftsTriggers = []string{
+	"posts_fts_ai", "posts_fts_au", "posts_fts_ad",
+	"comments_fts_ai", "comments_fts_au", "comments_fts_ad",
+}
+
+// InitSearch 创建全文索引表及触发器，并重建索引数据；
+// FTS5 不可用时返回错误，检索退化为 LIKE 匹配
+func InitSearch(db *gorm.DB) error {
+	ftsEnabled = false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 重建触发器，使其定义与当前版本保持一致
+		if err := dropSearchTriggers(tx); err != nil {
+			return err
+		}
+		for _, stmt := range ftsStatements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return rebuildSearchIndex(tx)
+	})
+	if err != nil {
+		// 移除可能由启用 FTS5 的版本创建的触发器，避免写入 posts、comments 时报错
+		_ = dropSearchTriggers(db)
+		return err
+	}
+	ftsEnabled = true
+	return nil
+}
+
+// 删除同步触发器；迁移删除触发器引用的字段前调用，下次启动时由 InitSearch 重新创建
+func dropSearchTriggers(db *gorm.DB) error {
+	for _, trigger := range ftsTriggers {
+		if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func rebuildSearchIndex(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM posts_fts").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`INSERT INTO posts_fts(rowid, title, content, comments)
+		SELECT p.id, p.title, p.content,
+			coalesce((SELECT group_concat(c.content, ' ') FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.status = 'approved'), '')
+		FROM posts p WHERE p.deleted_at IS NULL`).Error
+}
+
+// 拆分检索关键字
+func searchTerms(q string) []string {
+	return strings.Fields(strings.TrimSpace(q))
+}
+
+// trigram 分词要求每个关键字至少 3 个字符
+func useFTS(terms []string) bool {
+	if !ftsEnabled {
+		return false
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// 将关键字转换为 FTS5 短语查询，避免用户输入被当作查询语法
+func ftsQuery(terms []string) string {
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return strings.Join(phrases, " AND ")
+}
+
+func likeScope(terms []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, term := range terms {
+			pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term) + "%"
+			db = db.Where(`(p.title LIKE ? ESCAPE '\' OR p.content LIKE ? ESCAPE '\'
+				OR EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL AND c.status = 'approved' AND c.content LIKE ? ESCAPE '\'))`,
+				pattern, pattern, pattern)
+		}
+		return db
+	}
+}
+
+// SearchPost 按相关度检索文章，标题与片段中的命中词以高亮标记包裹
+func SearchPost(q string, pageIndex, pageSize int) ([]*SearchResult, error) {
+	var (
+		results []*SearchResult
+		terms   = searchTerms(q)
+	)
+	if len(terms) == 0 {
+		return results, nil
+	}
+	if useFTS(terms) {
+		db := DB.Table("posts_fts").
+			Select(`p.id post_id, highlight(posts_fts, 0, ?, ?) title,
+				snippet(posts_fts, -1, ?, ?, '…', 24) snippet,
+				bm25(posts_fts, 10.0, 1.0, 0.5) rank, p.published_at created_at`,
+				HighlightOpen, HighlightClose, HighlightOpen, HighlightClose).
+			Joins("inner join posts p on p.id = posts_fts.rowid and p.deleted_at is null and p.status = ?", PostStatusPublished).
+			Where("posts_fts MATCH ?", ftsQuery(terms)).
+			Order("rank")
+		if pageIndex > 0 {
+			db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+		}
+		err := db.Scan(&results).Error
+		return results, err
+	}
+
+	var posts []*Post
+	db := DB.Table("posts p").Select("p.*").Where("p.deleted_at is null and p.status = ?", PostStatusPublished).
+		Scopes(likeScope(terms)).
+		Order("p.published_at desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	if err := db.Scan(&posts).Error; err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		result := &SearchResult{
+			PostID:    post.ID,
+			Title:     markTerms(post.Title, terms),
+			Snippet:   markTerms(excerpt(post.Content, terms[0], 24), terms),
+			CreatedAt: post.CreatedAt,
+		}
+		if post.PublishedAt != nil {
+			result.CreatedAt = *post.PublishedAt
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func CountSearchPost(q string) (count int, err error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return 0, nil
+	}
+	if useFTS(terms) {
+		err = DB.Raw(`select count(*) from posts_fts
+			inner join posts p on p.id = posts_fts.rowid and p.deleted_at is null and p.status = ?
+			where posts_fts MATCH ?`, PostStatusPublished, ftsQuery(terms)).Row().Scan(&count)
+		return
+	}
+	var total int64
+	err = DB.Table("posts p").Where("p.deleted_at is null and p.status = ?", PostStatusPublished).Scopes(likeScope(terms)).Count(&total).Error
+	return int(total), err
+}
+
+// 截取关键字附近的正文，width 为关键字前后保留的字符数
+func excerpt(content, term string, width int) string {
+	runes := []rune(content)
+	start := 0
+	lower := strings.ToLower(content)
+	if idx := strings.Index(lower, strings.ToLower(term)); idx >= 0 && len(lower) == len(content) {
+		start = utf8.RuneCountInString(content[:idx]) - width
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + 2*width + utf8.RuneCountInString(term)
+	if end > len(runes) {
+		end = len(runes)
+	}
+	result := string(runes[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+// 用高亮标记包裹命中的关键字（忽略大小写）
+func markTerms(source string, terms []string) string {
+	lower := strings.ToLower(source)
+	marked := make([]bool, len(source))
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if len(term) == 0 || len(lower) != len(source) {
+			continue
+		}
+		for offset := 0; ; {
+			idx := strings.Index(lower[offset:], term)
+			if idx < 0 {
+				break
+			}
+			for i := offset + idx; i < offset+idx+len(term); i++ {
+				marked[i] = true
+			}
+			offset += idx + len(term)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(source); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(HighlightOpen)
+		}
+		b.WriteByte(source[i])
+		if marked[i] && (i == len(source)-1 || !marked[i+1]) {
+			b.WriteString(HighlightClose)
+		}
+	}
+	return b.String()
+}
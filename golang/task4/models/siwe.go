@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 以太坊钱包登录使用的 Provider 名称
+const ProviderEthereum = "ethereum"
+
+var ErrSiweNonceInvalid = errors.New("nonce invalid, expired or already used")
+
+// Sign-In with Ethereum 的一次性随机数
+type SiweNonce struct {
+	gorm.Model
+	Nonce     string `gorm:"type:varchar(32);uniqueIndex;not null"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func (SiweNonce) TableName() string {
+	return "siwe_nonces"
+}
+
+// IssueSiweNonce 生成随机数，EIP-4361 要求至少 8 位字母数字
+func IssueSiweNonce(ttl time.Duration) (string, error) {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	buf := make([]byte, 17)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = chars[n.Int64()]
+	}
+	nonce := string(buf)
+	err := DB.Create(&SiweNonce{Nonce: nonce, ExpiresAt: time.Now().Add(ttl)}).Error
+	return nonce, err
+}
+
+// ConsumeSiweNonce 使用随机数，每个随机数只能使用一次
+func ConsumeSiweNonce(nonce string, now time.Time) error {
+	result := DB.Model(&SiweNonce{}).
+		Where("nonce = ? and used_at is null and expires_at > ?", nonce, now).
+		UpdateColumn("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrSiweNonceInvalid
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 标签信息
+type Tag struct {
+	gorm.Model
+	Name  string `gorm:"uniqueIndex;not null"`
+	Posts []Post `gorm:"many2many:post_tags"`
+	Total int    `gorm:"->;-:migration"` // count of post
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// 分类信息
+type Category struct {
+	gorm.Model
+	Name  string `gorm:"not null"`
+	Slug  string `gorm:"uniqueIndex;not null"`
+	Posts []Post `gorm:"many2many:post_categories"`
+	Total int    `gorm:"->;-:migration"` // count of post
+}
+
+func (Category) TableName() string {
+	return "categories"
+}
+
+// SplitNames 拆分逗号分隔的名称，去除空白与重复项
+func SplitNames(source string) []string {
+	var (
+		names []string
+		seen  = map[string]bool{}
+	)
+	for _, name := range strings.FieldsFunc(source, func(r rune) bool {
+		return r == ',' || r == '，'
+	}) {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Slugify 将分类名称转换为 URL 中使用的 slug
+func Slugify(name string) string {
+	var (
+		b    strings.Builder
+		dash bool
+	)
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// Tag
+func GetOrCreateTags(names []string) ([]Tag, error) {
+	var tags []Tag
+	for _, name := range names {
+		var tag Tag
+		err := DB.Where(Tag{Name: name}).FirstOrCreate(&tag).Error
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func GetTagByName(name string) (*Tag, error) {
+	var tag Tag
+	err := DB.First(&tag, "name = ?", name).Error
+	return &tag, err
+}
+
+// 查询所有标签及其文章数量
+func ListTag() ([]*Tag, error) {
+	var tags []*Tag
+	err := DB.Model(&Tag{}).
+		Select("tags.*, count(p.id) total").
+		Joins("inner join post_tags pt on pt.tag_id = tags.id").
+		Joins("inner join posts p on p.id = pt.post_id and p.deleted_at is null and p.status = ?", PostStatusPublished).
+		Group("tags.id").
+		Order("total desc, tags.name").
+		Find(&tags).Error
+	return tags, err
+}
+
+func ListPostByTag(name string, pageIndex, pageSize int) ([]*Post, error) {
+	var posts []*Post
+	db := DB.Preload("Tags").Preload("Categories").
+		Joins("inner join post_tags pt on pt.post_id = posts.id").
+		Joins("inner join tags t on t.id = pt.tag_id").
+		Where("t.name = ?", name).
+		Scopes(Published).
+		Order("posts.published_at desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&posts).Error
+	return posts, err
+}
+
+func CountPostByTag(name string) (count int, err error) {
+	err = DB.Raw(`select count(*) from posts p
+		inner join post_tags pt on pt.post_id = p.id
+		inner join tags t on t.id = pt.tag_id
+		where t.name = ? and p.deleted_at is null and p.status = ?`, name, PostStatusPublished).Row().Scan(&count)
+	return
+}
+
+// Category
+func GetOrCreateCategories(names []string) ([]Category, error) {
+	var categories []Category
+	for _, name := range names {
+		slug := Slugify(name)
+		if len(slug) == 0 {
+			continue
+		}
+		var category Category
+		err := DB.Where(Category{Slug: slug}).Attrs(Category{Name: name}).FirstOrCreate(&category).Error
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func GetCategoryBySlug(slug string) (*Category, error) {
+	var category Category
+	err := DB.First(&category, "slug = ?", slug).Error
+	return &category, err
+}
+
+// 查询所有分类及其文章数量
+func ListCategory() ([]*Category, error) {
+	var categories []*Category
+	err := DB.Model(&Category{}).
+		Select("categories.*, count(p.id) total").
+		Joins("inner join post_categories pc on pc.category_id = categories.id").
+		Joins("inner join posts p on p.id = pc.post_id and p.deleted_at is null and p.status = ?", PostStatusPublished).
+		Group("categories.id").
+		Order("categories.name").
+		Find(&categories).Error
+	return categories, err
+}
+
+func ListPostByCategory(slug string, pageIndex, pageSize int) ([]*Post, error) {
+	var posts []*Post
+	db := DB.Preload("Tags").Preload("Categories").
+		Joins("inner join post_categories pc on pc.post_id = posts.id").
+		Joins("inner join categories c on c.id = pc.category_id").
+		Where("c.slug = ?", slug).
+		Scopes(Published).
+		Order("posts.published_at desc")
+	if pageIndex > 0 {
+		db = db.Limit(pageSize).Offset((pageIndex - 1) * pageSize)
+	}
+	err := db.Find(&posts).Error
+	return posts, err
+}
+
+func CountPostByCategory(slug string) (count int, err error) {
+	err = DB.Raw(`select count(*) from posts p
+		inner join post_categories pc on pc.post_id = p.id
+		inner join categories c on c.id = pc.category_id
+		where c.slug = ? and p.deleted_at is null and p.status = ?`, slug, PostStatusPublished).Row().Scan(&count)
+	return
+}
+
+// 替换文章关联的标签与分类
+func (post *Post) ReplaceTags(tags []Tag) error {
+	return DB.Model(post).Association("Tags").Replace(tags)
+}
+
+func (post *Post) ReplaceCategories(categories []Category) error {
+	return DB.Model(post).Association("Categories").Replace(categories)
+}
+
+// 逗号拼接的标签名，用于编辑页面回显
+func (post *Post) TagNames() string {
+	names := make([]string, 0, len(post.Tags))
+	for _, tag := range post.Tags {
+		names = append(names, tag.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (post *Post) CategoryNames() string {
+	names := make([]string, 0, len(post.Categories))
+	for _, category := range post.Categories {
+		names = append(names, category.Name)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-blog/system"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused, all sessions of this login are revoked")
+	ErrTokenRevoked        = errors.New("token revoked")
+)
+
+// 刷新令牌，只保存令牌的哈希；同一次登录轮换出的令牌属于同一个 Family
+type RefreshToken struct {
+	gorm.Model
+	UserID     uint   `gorm:"index;not null"`
+	TokenHash  string `gorm:"type:char(64);uniqueIndex;not null"`
+	Family     string `gorm:"type:varchar(32);index;not null"`
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy uint // 轮换后的新令牌 ID
+	UserAgent  string
+	IP         string
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// 已吊销的访问令牌，按 jti 记录，过期后可清理
+type RevokedToken struct {
+	gorm.Model
+	JTI       string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID    uint   `gorm:"index"`
+	ExpiresAt time.Time
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// AccessTokenTTL 访问令牌有效期，取配置文件中的 jwt.access_ttl（秒）
+func AccessTokenTTL() time.Duration {
+	if cfg := system.GetConfiguration(); cfg != nil && cfg.JWT.AccessTTL > 0 {
+		return time.Duration(cfg.JWT.AccessTTL) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// RefreshTokenTTL 刷新令牌有效期，取配置文件中的 jwt.refresh_ttl（秒）
+func RefreshTokenTTL() time.Duration {
+	if cfg := system.GetConfiguration(); cfg != nil && cfg.JWT.RefreshTTL > 0 {
+		return time.Duration(cfg.JWT.RefreshTTL) * time.Second
+	}
+	return 7 * 24 * time.Hour
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenID 生成访问令牌的 jti
+func NewTokenID() (string, error) {
+	return randomToken(16)
+}
+
+func createRefreshToken(tx *gorm.DB, userID uint, family, userAgent, ip string) (string, *RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	token := &RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		Family:    family,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if err = tx.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+// IssueRefreshToken 登录时签发新的刷新令牌，返回令牌原文
+func IssueRefreshToken(userID uint, userAgent, ip string) (string, *RefreshToken, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return "", nil, err
+	}
+	return createRefreshToken(DB, userID, family, userAgent, ip)
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的刷新令牌，旧令牌随即失效；
+// 已失效的令牌被再次使用时视为泄露，吊销同一 Family 下的全部令牌
+func RotateRefreshToken(raw, userAgent, ip string) (string, *RefreshToken, error) {
+	var (
+		newRaw string
+		token  *RefreshToken
+		family string
+	)
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var old RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(raw)).Limit(1).Find(&old).Error; err != nil {
+			return err
+		}
+		if old.ID == 0 || old.ExpiresAt.Before(time.Now()) {
+			return ErrRefreshTokenInvalid
+		}
+		// 以条件更新吊销旧令牌，并发请求中只有一个能成功，其余视为重复使用
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? and revoked_at is null", old.ID).
+			Updates(map[string]interface{}{"revoked_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			family = old.Family
+			return ErrRefreshTokenReused
+		}
+		var err error
+		newRaw, token, err = createRefreshToken(tx, old.UserID, old.Family, userAgent, ip)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&old).Updates(map[string]interface{}{"replaced_by": token.ID}).Error
+	})
+	// 事务回滚后再吊销，保证吊销操作生效
+	if errors.Is(err, ErrRefreshTokenReused) {
+		_ = revokeRefreshTokens(DB.Where("family = ?", family))
+	}
+	return newRaw, token, err
+}
+
+func revokeRefreshTokens(db *gorm.DB) error {
+	return db.Model(&RefreshToken{}).
+		Where("revoked_at is null").
+		UpdateColumn("revoked_at", time.Now()).Error
+}
+
+// RevokeRefreshToken 退出登录时吊销刷新令牌
+func RevokeRefreshToken(raw string) error {
+	return revokeRefreshTokens(DB.Where("token_hash = ?", hashToken(raw)))
+}
+
+// RevokeToken 将访问令牌加入吊销列表
+func RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if len(jti) == 0 {
+		return nil
+	}
+	var count int64
+	DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count)
+	if count > 0 {
+		return nil
+	}
+	return DB.Create(&RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}).Error
+}
+
+// IsTokenRevoked 访问令牌是否已被吊销
+func IsTokenRevoked(jti string) bool {
+	var count int64
+	DB.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count)
+	return count > 0
+}
+
+// LogoutEverywhere 吊销用户的全部刷新令牌，并使已签发的访问令牌全部失效
+func (user *User) LogoutEverywhere() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeRefreshTokens(tx.Where("user_id = ?", user.ID)); err != nil {
+			return err
+		}
+		err := tx.Model(user).UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(user).Select("token_version").Take(user).Error
+	})
+}
+
+// PurgeExpiredTokens 清理已过期的刷新令牌、吊销记录与钱包登录随机数，返回清理数量
+func PurgeExpiredTokens(now time.Time) (int64, error) {
+	var count int64
+	for _, model := range []interface{}{&RefreshToken{}, &RevokedToken{}, &SiweNonce{}} {
+		result := DB.Unscoped().Where("expires_at < ?", now).Delete(model)
+		if result.Error != nil {
+			return count, result.Error
+		}
+		count += result.RowsAffected
+	}
+	return count, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 按天记录的日期格式
+const postViewDay = "2006-01-02"
+
+// 文章每日阅读数，用于统计一段时间内的阅读排行
+type PostView struct {
+	PostID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Day    string `gorm:"primaryKey;type:varchar(10)"` // 2006-01-02，服务器本地时间
+	Count  int    `gorm:"not null;default:0"`
+}
+
+func (PostView) TableName() string {
+	return "post_views"
+}
+
+// AddPostViews 批量累加阅读数：posts.view 为总阅读数，post_views 为当天的阅读数
+func AddPostViews(counts map[uint]int, now time.Time) error {
+	ids := make([]uint, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	day := now.Format(postViewDay)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			// 原生 SQL 不触发内容变更事件，阅读数变化不清空页面缓存
+			result := tx.Exec("UPDATE posts SET view = view + ? WHERE id = ?", counts[id], id)
+			if result.Error != nil {
+				return result.Error
+			}
+			// 文章已被彻底删除
+			if result.RowsAffected == 0 {
+				continue
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "post_id"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("post_views.count + excluded.count")}),
+			}).Create(&PostView{PostID: id, Day: day, Count: counts[id]}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 最近 days 天（含当天）的起始日期
+func viewWindowStart(now time.Time, days int) string {
+	return now.AddDate(0, 0, 1-days).Format(postViewDay)
+}
+
+// ListMostReadPost 阅读最多的文章；days 大于 0 时统计最近 days 天的阅读数，否则按总阅读数排序
+func ListMostReadPost(days, limit int) ([]*Post, error) {
+	var posts []*Post
+	query := DB.Scopes(Published).Preload("User").Limit(limit)
+	if days <= 0 {
+		err := query.Select("posts.*, posts.view AS view_total").Order("view desc, id desc").Find(&posts).Error
+		return posts, err
+	}
+	err := query.Select("posts.*, v.total AS view_total").
+		Joins("INNER JOIN (SELECT post_id, SUM(count) total FROM post_views WHERE day >= ? GROUP BY post_id) v ON v.post_id = posts.id", viewWindowStart(time.Now(), days)).
+		Order("v.total desc, posts.id desc").Find(&posts).Error
+	return posts, err
+}
+
+// ListTrendingPost 近期热门文章：最近 days 天的阅读数按天衰减加权，当天权重为 1，前一天为 1/2，依此类推
+func ListTrendingPost(days, limit int) ([]*Post, error) {
+	if days <= 0 {
+		days = 1
+	}
+	now := time.Now()
+	var posts []*Post
+	err := DB.Scopes(Published).Preload("User").Limit(limit).
+		Select("posts.*, v.total AS view_total").
+		Joins("INNER JOIN (SELECT post_id, SUM(count) total, SUM(count * 1.0 / (julianday(?) - julianday(day) + 1)) score FROM post_views WHERE day >= ? GROUP BY post_id) v ON v.post_id = posts.id",
+			now.Format(postViewDay), viewWindowStart(now, days)).
+		Order("v.score desc, posts.id desc").Find(&posts).Error
+	return posts, err
+}
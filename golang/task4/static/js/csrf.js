@@ -0,0 +1,4 @@
+// ajax 请求统一携带 CSRF token，token 取自页面中的 csrf-token meta 标签
+$.ajaxSetup({
+    headers: {"X-CSRF-Token": $('meta[name="csrf-token"]').attr("content")}
+});
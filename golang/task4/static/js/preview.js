@@ -0,0 +1,9 @@
+// 编辑器预览：由服务端渲染，与文章页面的显示效果一致
+function serverPreview(plainText, preview) {
+    $.post("/admin/preview", {content: plainText}, function (result) {
+        if (result.succeed) {
+            preview.innerHTML = result.html;
+        }
+    }, "json");
+    return preview.innerHTML || "Loading...";
+}
@@ -0,0 +1,22 @@
+// Sign-In with Ethereum：通过钱包（window.ethereum）对服务端生成的 EIP-4361 消息签名
+// url 为提交签名的地址，登录为 /siwe/verify，绑定为 /admin/siwe/link
+function signInWithEthereum(url, done) {
+    if (!window.ethereum) {
+        alert("No Ethereum wallet found, please install MetaMask or another wallet.");
+        return;
+    }
+    var address;
+    ethereum.request({method: "eth_requestAccounts"}).then(function (accounts) {
+        address = accounts[0];
+        return ethereum.request({method: "eth_chainId"});
+    }).then(function (chainId) {
+        return $.get("/siwe/nonce", {address: address, chain_id: parseInt(chainId, 16)});
+    }).then(function (data) {
+        return ethereum.request({method: "personal_sign", params: [data.message, address]}).then(function (signature) {
+            return $.post(url, {message: data.message, signature: signature});
+        });
+    }).then(done, function (err) {
+        var message = err && err.responseJSON ? err.responseJSON.error : (err && err.message) || err;
+        alert("Sign-in with Ethereum failed: " + message);
+    });
+}
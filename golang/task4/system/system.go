@@ -10,13 +10,115 @@ import (
 
 type (
 	Database struct {
-		Dialect string `toml:"dialect"`
-		DSN     string `toml:"dsn"`
+		Dialect     string `toml:"dialect"`
+		DSN         string `toml:"dsn"`
+		AutoMigrate bool   `toml:"auto_migrate"` // 启动时自动执行未执行的迁移，关闭后需先运行 -migrate up
 	}
 
 	JWT struct {
-		SK     string `toml:"sk"`
-		Issuer string `toml:"issuer"`
+		SK         string   `toml:"sk"`
+		Issuer     string   `toml:"issuer"`
+		AccessTTL  int      `toml:"access_ttl"`  // 访问令牌有效期（秒）
+		RefreshTTL int      `toml:"refresh_ttl"` // 刷新令牌有效期（秒）
+		SigningKid string   `toml:"signing_kid"` // 签名密钥的 kid，为空时使用 sk 以 HS256 签名
+		Keys       []JWTKey `toml:"keys"`
+	}
+
+	// 非对称签名密钥，轮换期间旧密钥只需保留公钥
+	JWTKey struct {
+		Kid        string `toml:"kid"`
+		Algorithm  string `toml:"algorithm"`   // RS256 或 EdDSA
+		PrivateKey string `toml:"private_key"` // 私钥文件（PEM），签名密钥需要
+		PublicKey  string `toml:"public_key"`  // 公钥文件（PEM），未配置时由私钥推导
+	}
+
+	// 登录保护
+	Login struct {
+		MaxFailures       int `toml:"max_failures"`        // 同一用户名连续失败多少次后锁定
+		IPMaxFailures     int `toml:"ip_max_failures"`     // 同一 IP 连续失败多少次后锁定
+		CaptchaAfter      int `toml:"captcha_after"`       // 连续失败多少次后需要验证码
+		LockoutSeconds    int `toml:"lockout_seconds"`     // 首次锁定时长，之后每次翻倍
+		MaxLockoutSeconds int `toml:"max_lockout_seconds"` // 最长锁定时长
+	}
+
+	// 邮件发送，driver 为 smtp 或 file（写入 outbox 目录，用于本地开发与测试）
+	Mail struct {
+		Driver   string `toml:"driver"`
+		Host     string `toml:"host"`
+		Port     int    `toml:"port"` // 465 端口使用 TLS 直连，其他端口支持 STARTTLS
+		Username string `toml:"username"`
+		Password string `toml:"password"`
+		From     string `toml:"from"`
+		Outbox   string `toml:"outbox"` // file 方式的邮件保存目录
+	}
+
+	// 附件上传，存储方式由 file_server 选择：local 或 s3
+	Upload struct {
+		MaxSize   int64    `toml:"max_size"`   // 单个文件大小上限（字节）
+		FileTypes []string `toml:"file_types"` // 允许上传的 MIME 类型，如 image/*
+		LocalPath string   `toml:"local_path"` // local 方式的存储目录
+		LocalURL  string   `toml:"local_url"`  // local 方式的访问地址前缀
+		S3        S3       `toml:"s3"`
+
+		MaxWidth      int            `toml:"max_width"`      // 图片宽度上限（像素），0 为不限制
+		MaxHeight     int            `toml:"max_height"`     // 图片高度上限（像素），0 为不限制
+		StripMetadata bool           `toml:"strip_metadata"` // 去除 EXIF/GPS 等元数据
+		JPEGQuality   int            `toml:"jpeg_quality"`   // 重新编码 JPEG 时的质量
+		Variants      []ImageVariant `toml:"variants"`       // 缩放生成的图片尺寸
+
+		OrphanGraceSeconds int `toml:"orphan_grace_seconds"` // 未被文章引用的附件保留时长，超过后自动删除
+	}
+
+	// 公开页面缓存
+	Cache struct {
+		Enabled bool `toml:"enabled"`
+		Pages   int  `toml:"pages"` // 缓存的页面数上限，超出后淘汰最久未访问的页面
+	}
+
+	// 以太坊钱包登录
+	Siwe struct {
+		ChainIDs         []int64 `toml:"chain_ids"`          // 允许的链 ID
+		ClockSkewSeconds int     `toml:"clock_skew_seconds"` // 校验签发时间与有效期时允许的时钟偏差
+		NonceRateLimit   int     `toml:"nonce_rate_limit"`   // 每个 IP 每分钟最多获取的随机数
+	}
+
+	// 文章阅读计数，键名 views 已用于模板目录
+	PostViews struct {
+		WindowSeconds int `toml:"window_seconds"` // 同一访客在此时间内重复访问同一文章只计一次
+		FlushSeconds  int `toml:"flush_seconds"`  // 缓冲的阅读数写入数据库的间隔
+		BatchSize     int `toml:"batch_size"`     // 缓冲的阅读数达到此数量时提前写入
+	}
+
+	// 缩放图片尺寸，宽度不超过原图时才生成
+	ImageVariant struct {
+		Name  string `toml:"name"`
+		Width int    `toml:"width"`
+	}
+
+	// S3 兼容的对象存储（AWS S3、MinIO 等）
+	S3 struct {
+		Endpoint  string `toml:"endpoint"` // 如 https://s3.amazonaws.com、http://127.0.0.1:9000
+		Region    string `toml:"region"`
+		Bucket    string `toml:"bucket"`
+		AccessKey string `toml:"access_key"`
+		SecretKey string `toml:"secret_key"`
+		PathStyle bool   `toml:"path_style"` // 使用 {endpoint}/{bucket}/{key} 形式的地址，MinIO 需要开启
+		PublicURL string `toml:"public_url"` // 返回的访问地址前缀，未配置时使用对象地址
+	}
+
+	// OAuth2 登录，type 为 github 或 oidc；端点未配置时 github 使用官方地址
+	OAuthProvider struct {
+		Name         string   `toml:"name"` // 路由中的名称，/oauth/{name}
+		Type         string   `toml:"type"`
+		Title        string   `toml:"title"` // 登录按钮上显示的名称
+		ClientID     string   `toml:"client_id"`
+		ClientSecret string   `toml:"client_secret"`
+		AuthURL      string   `toml:"auth_url"`
+		TokenURL     string   `toml:"token_url"`
+		UserInfoURL  string   `toml:"userinfo_url"`
+		EmailsURL    string   `toml:"emails_url"`   // 仅 github，读取已验证的邮箱
+		RedirectURL  string   `toml:"redirect_url"` // 未配置时为 {domain}/oauth/{name}/callback
+		Scopes       []string `toml:"scopes"`
 	}
 
 	Author struct {
@@ -31,18 +133,28 @@ type (
 	}
 
 	Configuration struct {
-		Addr          string      `toml:"addr"`
-		Title         string      `toml:"title"`
-		SessionSecret string      `toml:"session_secret"`
-		Domain        string      `toml:"domain"`
-		FileServer    string      `toml:"file_server"`
-		NotifyEmails  string      `toml:"notify_emails"`
-		PageSize      int         `toml:"page_size"`
-		PublicDir     string      `toml:"public"`
-		ViewDir       string      `toml:"views"`
-		Database      Database    `toml:"database"`
-		Navigators    []Navigator `toml:"navigators"`
-		JWT           JWT         `toml:"jwt"`
+		Addr            string          `toml:"addr"`
+		Title           string          `toml:"title"`
+		SessionSecret   string          `toml:"session_secret"`
+		Domain          string          `toml:"domain"`
+		FileServer      string          `toml:"file_server"`
+		NotifyEmails    string          `toml:"notify_emails"`
+		PageSize        int             `toml:"page_size"`
+		CommentMaxDepth int             `toml:"comment_max_depth"` // 评论最大嵌套层数
+		DefaultRole     string          `toml:"default_role"`      // 新注册用户的角色
+		PublicDir       string          `toml:"public"`
+		ViewDir         string          `toml:"views"`
+		Database        Database        `toml:"database"`
+		Navigators      []Navigator     `toml:"navigators"`
+		JWT             JWT             `toml:"jwt"`
+		Login           Login           `toml:"login"`
+		Mail            Mail            `toml:"mail"`
+		Upload          Upload          `toml:"upload"`
+		Cache           Cache           `toml:"cache"`
+		PostViews       PostViews       `toml:"post_views"`
+		Siwe            Siwe            `toml:"siwe"`
+		OAuth           []OAuthProvider `toml:"oauth"`
+		Author          Author          `toml:"author"`
 	}
 )
 
@@ -52,23 +164,79 @@ func (a Author) String() string {
 
 var configuration *Configuration
 
+// 生成的示例配置中 domain 的占位符，需要替换为站点地址
+const DomainPlaceholder = "[!!]"
+
 func defaultConfig() Configuration {
 	return Configuration{
 		JWT: JWT{
-			Issuer: "personal-blog-server",
-			SK:     "776df678g6hd78f6g8h7df8gdh",
+			Issuer:     "personal-blog-server",
+			SK:         "776df678g6hd78f6g8h7df8gdh",
+			AccessTTL:  900,
+			RefreshTTL: 604800,
 		},
-		Addr:          ":8090",
-		SessionSecret: "asdf89sd7f98a9sd8f78asd",
-		Domain:        "https://ismjt.com",
-		Title:         "Personal blog",
-		FileServer:    "local",
-		PageSize:      10,
-		PublicDir:     "static",
-		ViewDir:       "views/**/*",
+		Addr:            ":8090",
+		SessionSecret:   "asdf89sd7f98a9sd8f78asd",
+		Domain:          "https://ismjt.com",
+		Title:           "Personal blog",
+		FileServer:      "local",
+		PageSize:        10,
+		CommentMaxDepth: 3,
+		DefaultRole:     "author",
+		PublicDir:       "static",
+		ViewDir:         "views/**/*",
 		Database: Database{
-			Dialect: "sqlite",
-			DSN:     "personal_blog.db",
+			Dialect:     "sqlite",
+			DSN:         "personal_blog.db",
+			AutoMigrate: true,
+		},
+		Login: Login{
+			MaxFailures:       5,
+			IPMaxFailures:     20,
+			CaptchaAfter:      3,
+			LockoutSeconds:    60,
+			MaxLockoutSeconds: 86400,
+		},
+		Upload: Upload{
+			MaxSize:            10 << 20,
+			FileTypes:          []string{"image/*"},
+			LocalPath:          "static/upload",
+			LocalURL:           "/static/upload/",
+			MaxWidth:           8000,
+			MaxHeight:          8000,
+			StripMetadata:      true,
+			JPEGQuality:        85,
+			OrphanGraceSeconds: 7 * 86400,
+			Variants: []ImageVariant{
+				{Name: "thumbnail", Width: 150},
+				{Name: "medium", Width: 800},
+			},
+			S3: S3{
+				Region:    "us-east-1",
+				PathStyle: true,
+			},
+		},
+		Cache: Cache{
+			Enabled: true,
+			Pages:   256,
+		},
+		Siwe: Siwe{
+			ChainIDs:         []int64{1},
+			ClockSkewSeconds: 300,
+			NonceRateLimit:   10,
+		},
+		PostViews: PostViews{
+			WindowSeconds: 1800,
+			FlushSeconds:  30,
+			BatchSize:     1000,
+		},
+		Mail: Mail{
+			Driver: "file",
+			Port:   25,
+			Outbox: "outbox",
+		},
+		Author: Author{
+			Name: "Personal blog",
 		},
 		Navigators: []Navigator{
 			{
@@ -95,8 +263,7 @@ func LoadConfiguration(path string) error {
 
 func Generate() error {
 	config := defaultConfig()
-	placeholder := "[!!]"
-	config.Domain = placeholder
+	config.Domain = DomainPlaceholder
 	data, err := toml.Marshal(config)
 	if err != nil {
 		return err
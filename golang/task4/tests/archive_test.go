@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"go-blog/controllers"
+	"go-blog/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestArchive(t *testing.T) {
+	setupTestDB()
+	var posts []*models.Post
+	for _, day := range []string{"2001-03-05", "2001-03-05", "2001-03-20", "2001-07-01"} {
+		published, _ := time.Parse("2006-01-02", day)
+		post := &models.Post{Title: "archive " + day, Content: "archive", UserID: 1, PublishedAt: &published}
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		defer models.DB.Unscoped().Delete(post)
+		posts = append(posts, post)
+	}
+	// 软删除的文章不计入归档
+	if err := posts[2].LogicDelete(); err != nil {
+		t.Fatalf("LogicDelete err: %v", err)
+	}
+
+	if count, _ := models.CountPostByArchive(2001, 0); count != 3 {
+		t.Errorf("Expected 3 posts in 2001, got %d", count)
+	}
+	if count, _ := models.CountPostByArchive(2001, 3); count != 2 {
+		t.Errorf("Expected 2 posts in 2001-03, got %d", count)
+	}
+	if list, _ := models.ListPostByArchive(2001, 0, 1, 2); len(list) != 2 || list[0].ID != posts[3].ID {
+		t.Errorf("Expected first page ordered by publish time, got %d posts", len(list))
+	}
+
+	years, err := models.ListArchiveYears()
+	if err != nil {
+		t.Fatalf("ListArchiveYears err: %v", err)
+	}
+	var summary *models.ArchiveYear
+	for _, year := range years {
+		if year.Year == 2001 {
+			summary = year
+		}
+	}
+	if summary == nil || summary.Total != 3 || len(summary.Months) != 2 || summary.Months[0].Month != 7 {
+		t.Errorf("Unexpected year summary: %+v", summary)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/archives/:year/:month/calendar", controllers.APIArchiveCalendar)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/archives/2001/3/calendar", nil))
+	var res struct {
+		Payload struct {
+			Total int
+			Days  []models.ArchiveDay
+		}
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Unexpected response: %s", w.Body.String())
+	}
+	if res.Payload.Total != 2 || len(res.Payload.Days) != 31 || res.Payload.Days[4].Total != 2 || res.Payload.Days[19].Total != 0 {
+		t.Errorf("Unexpected calendar: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/archives/2001/13/calendar", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid month rejected, got %d", w.Code)
+	}
+}
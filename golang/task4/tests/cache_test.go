@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"go-blog/controllers"
+	"go-blog/helpers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLRU(t *testing.T) {
+	cache := helpers.NewLRU(2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Set("c", 3)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected least recently used entry evicted")
+	}
+	if v, ok := cache.Get("a"); !ok || v.(int) != 1 {
+		t.Errorf("Expected entry a kept, got %v", v)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCachePage(t *testing.T) {
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+	setupTestDB()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("blog-session", cookie.NewStore([]byte("secret"))))
+	calls := 0
+	router.GET("/cached", controllers.CachePage(func(c *gin.Context) {
+		calls++
+		c.String(http.StatusOK, "page")
+	}))
+	get := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/cached", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	controllers.InvalidateCache()
+
+	w := get()
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || w.Body.String() != "page" || len(etag) == 0 || len(lastModified) == 0 {
+		t.Fatalf("Unexpected response: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w = get(); w.Body.String() != "page" || calls != 1 {
+		t.Errorf("Expected cached page served, handler called %d times", calls)
+	}
+	if w = get("If-None-Match", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 for matching ETag, got %d", w.Code)
+	}
+	if w = get("If-Modified-Since", lastModified); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+
+	// 文章变更后缓存失效
+	post := &models.Post{Title: "cache", Content: "cache", UserID: 1}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	defer models.DB.Unscoped().Delete(post)
+	if get(); calls != 2 {
+		t.Errorf("Expected page rendered again after post change, handler called %d times", calls)
+	}
+
+	// 携带认证信息的请求不使用缓存，仍可协商 ETag
+	if w = get("Authorization", "Bearer token"); calls != 3 || w.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("Expected private response, handler called %d times", calls)
+	}
+	if w = get("Authorization", "Bearer token", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for private page, got %d", w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"go-blog/models"
+	"testing"
+)
+
+func TestCommentTree(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	post := &models.Post{Title: "comment post", Content: "content"}
+	other := &models.Post{Title: "other post", Content: "content"}
+	for _, p := range []*models.Post{post, other} {
+		if err := p.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+
+	root := &models.Comment{PostID: post.ID, Content: "root"}
+	if err := root.Insert(); err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+	reply := &models.Comment{PostID: post.ID, Content: "reply"}
+	if err := reply.SetParent(root); err != nil {
+		t.Fatalf("SetParent err: %v", err)
+	}
+	if err := reply.Insert(); err != nil {
+		t.Fatalf("Failed to insert reply: %v", err)
+	}
+	nested := &models.Comment{PostID: post.ID, Content: "nested"}
+	if err := nested.SetParent(reply); err != nil {
+		t.Fatalf("SetParent err: %v", err)
+	}
+	if err := nested.Insert(); err != nil {
+		t.Fatalf("Failed to insert nested reply: %v", err)
+	}
+
+	// 默认最大嵌套 3 层
+	tooDeep := &models.Comment{PostID: post.ID, Content: "too deep"}
+	if err := tooDeep.SetParent(nested); err != models.ErrCommentTooDeep {
+		t.Errorf("Expected ErrCommentTooDeep, got %v", err)
+	}
+	mismatch := &models.Comment{PostID: other.ID, Content: "mismatch"}
+	if err := mismatch.SetParent(root); err != models.ErrCommentParentMismatch {
+		t.Errorf("Expected ErrCommentParentMismatch, got %v", err)
+	}
+
+	tree, err := models.ListCommentTreeByPostID(post.ID)
+	if err != nil {
+		t.Fatalf("ListCommentTreeByPostID err: %v", err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 {
+		t.Fatalf("Unexpected comment tree: %+v", tree)
+	}
+	if tree[0].Children[0].Children[0].Content != "nested" {
+		t.Errorf("Expected nested reply, got %q", tree[0].Children[0].Children[0].Content)
+	}
+}
+
+func TestCommentModeration(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	const author, visitor = 101, 102
+	authorUser := &models.User{Role: models.RoleAuthor}
+	authorUser.ID = author
+	visitorUser := &models.User{Role: models.RoleVisitor}
+	visitorUser.ID = visitor
+	post := &models.Post{Title: "moderation post", Content: "content", UserID: author}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	// 首次评论的访客需要人工审核，作者本人的评论直接通过
+	first := &models.Comment{PostID: post.ID, UserID: visitor, Content: "first"}
+	first.Moderate(post)
+	own := &models.Comment{PostID: post.ID, UserID: author, Content: "own"}
+	own.Moderate(post)
+	for _, comment := range []*models.Comment{first, own} {
+		if err := comment.Insert(); err != nil {
+			t.Fatalf("Failed to insert comment: %v", err)
+		}
+	}
+	if first.Status != models.CommentStatusPending || own.Status != models.CommentStatusApproved {
+		t.Fatalf("Unexpected status: first=%s own=%s", first.Status, own.Status)
+	}
+	tree, _ := models.ListCommentTreeByPostID(post.ID)
+	if len(tree) != 1 {
+		t.Errorf("Expected only approved comments to be public, got %d", len(tree))
+	}
+	unread, _ := models.ListUnreadComment(authorUser)
+	if len(unread) != 1 || unread[0].ID != first.ID {
+		t.Errorf("Expected 1 unread comment, got %d", len(unread))
+	}
+
+	// 只有文章作者才能审核
+	if count, _ := models.UpdateCommentStatus(visitorUser, []uint{first.ID}, models.CommentStatusApproved); count != 0 {
+		t.Errorf("Expected visitor not to moderate comments, got %d", count)
+	}
+	if count, err := models.UpdateCommentStatus(authorUser, []uint{first.ID}, models.CommentStatusApproved); err != nil || count != 1 {
+		t.Fatalf("UpdateCommentStatus err: %v, count %d", err, count)
+	}
+
+	// 已有评论通过审核的访客成为受信任的评论者
+	second := &models.Comment{PostID: post.ID, UserID: visitor, Content: "second"}
+	second.Moderate(post)
+	if second.Status != models.CommentStatusApproved {
+		t.Errorf("Expected trusted commenter to be auto approved, got %s", second.Status)
+	}
+	post.CommentApproval = models.CommentApprovalManual
+	second.Moderate(post)
+	if second.Status != models.CommentStatusPending {
+		t.Errorf("Expected manual rule to hold every comment, got %s", second.Status)
+	}
+
+	// 编辑后重新审核，唯一通过的评论不能让自己继续被信任
+	post.CommentApproval = models.CommentApprovalTrusted
+	first.Content = "edited"
+	first.Moderate(post)
+	if err := first.UpdateContent(); err != nil {
+		t.Fatalf("UpdateContent err: %v", err)
+	}
+	if edited, _ := models.GetCommentById(first.ID); edited.Status != models.CommentStatusPending || edited.Content != "edited" {
+		t.Errorf("Expected edited comment back to pending, got %s", edited.Status)
+	}
+}
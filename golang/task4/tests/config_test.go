@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"go-blog/system"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 示例配置与 -g 生成的配置都能被重新加载
+func TestLoadSampleConfiguration(t *testing.T) {
+	defer system.LoadConfiguration("../conf/conf.toml")
+
+	sample, _ := filepath.Abs("../conf/conf.sample.toml")
+	if err := system.LoadConfiguration(sample); err != nil {
+		t.Fatalf("Load conf.sample.toml err: %v", err)
+	}
+	if cfg := system.GetConfiguration(); cfg.ViewDir != "views/**/*" || cfg.PostViews.WindowSeconds != 1800 {
+		t.Errorf("Unexpected sample configuration: %q %+v", cfg.ViewDir, cfg.PostViews)
+	}
+
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("conf", os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.Generate(); err != nil {
+		t.Fatalf("Generate err: %v", err)
+	}
+	if err := system.LoadConfiguration("conf/conf.sample.toml"); err != nil {
+		t.Fatalf("Load generated configuration err: %v", err)
+	}
+	if cfg := system.GetConfiguration(); cfg.Domain != system.DomainPlaceholder || cfg.PostViews.FlushSeconds != 30 {
+		t.Errorf("Unexpected generated configuration: %+v", cfg.PostViews)
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"go-blog/controllers"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRFToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("blog-session", cookie.NewStore([]byte("test-secret"))))
+	router.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, controllers.CSRFToken(c))
+	})
+	router.POST("/form", func(c *gin.Context) {
+		if !controllers.VerifyCSRFToken(c) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	token := w.Body.String()
+	cookies := w.Result().Cookies()
+	if len(token) == 0 || len(cookies) == 0 {
+		t.Fatalf("Expected token and session cookie, got %q", token)
+	}
+
+	post := func(header, field string) int {
+		form := url.Values{}
+		if len(field) > 0 {
+			form.Set(controllers.CSRFFormField, field)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(header) > 0 {
+			req.Header.Set(controllers.CSRFHeader, header)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := post("", ""); code != http.StatusForbidden {
+		t.Errorf("Expected 403 without token, got %d", code)
+	}
+	if code := post("forged", ""); code != http.StatusForbidden {
+		t.Errorf("Expected 403 with wrong token, got %d", code)
+	}
+	if code := post(token, ""); code != http.StatusOK {
+		t.Errorf("Expected 200 with header token, got %d", code)
+	}
+	if code := post("", token); code != http.StatusOK {
+		t.Errorf("Expected 200 with form token, got %d", code)
+	}
+
+	// 模板输出需转义
+	field := string(controllers.CSRFField(`"><script>`))
+	if strings.Contains(field, "<script>") {
+		t.Errorf("CSRFField not escaped: %s", field)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/xml"
+	"go-blog/controllers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeedConditionalGet(t *testing.T) {
+	db := setupTestDB()
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db.Exec("DELETE FROM posts")
+	post := &models.Post{Title: "Feed post", Content: "![logo](/static/img/avatar.png)"}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/feed.rss", controllers.Feed(controllers.FeedRSS))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed.rss", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var feed struct {
+		Items []struct {
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to parse rss: %v", err)
+	}
+	domain := strings.TrimSuffix(system.GetConfiguration().Domain, "/")
+	if len(feed.Items) != 1 || !strings.HasPrefix(feed.Items[0].Link, domain+"/post/") {
+		t.Fatalf("Expected one item with absolute link, got %+v", feed.Items)
+	}
+	if !strings.Contains(feed.Items[0].Description, `src="`+domain+`/static/img/avatar.png"`) {
+		t.Errorf("Expected absolute image url in content, got %s", feed.Items[0].Description)
+	}
+
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("Expected ETag and Last-Modified headers")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/feed.rss", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for If-Modified-Since, got %d", w.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"go-blog/helpers"
+	"go-blog/system"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	helpers.SetMailer(&helpers.FileMailer{Dir: dir})
+	defer helpers.SetMailer(nil)
+
+	if err := helpers.SendMail([]string{"someone@example.org"}, "邮箱验证", "hello\nworld"); err != nil {
+		t.Fatalf("SendMail err: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 mail in outbox, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	mail := string(data)
+	if !strings.Contains(mail, "To: someone@example.org\r\n") || !strings.Contains(mail, "hello\r\nworld") {
+		t.Errorf("Unexpected mail content: %s", mail)
+	}
+	if strings.Contains(mail, "邮箱验证") {
+		t.Errorf("Subject should be encoded: %s", mail)
+	}
+}
+
+func TestSignedUserToken(t *testing.T) {
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+	now := time.Now()
+	token := helpers.SignUserToken(helpers.TokenResetPassword, 42, "hash-1", now.Add(time.Hour))
+
+	if id, err := helpers.UserIDFromToken(token); err != nil || id != 42 {
+		t.Fatalf("UserIDFromToken = %d, %v", id, err)
+	}
+	if err := helpers.VerifyUserToken(helpers.TokenResetPassword, token, "hash-1", now); err != nil {
+		t.Errorf("Expected valid token, got %v", err)
+	}
+	// 密码修改后令牌失效
+	if err := helpers.VerifyUserToken(helpers.TokenResetPassword, token, "hash-2", now); err == nil {
+		t.Error("Expected token invalid after state changed")
+	}
+	// 用途不同的令牌不能混用
+	if err := helpers.VerifyUserToken(helpers.TokenVerifyEmail, token, "hash-1", now); err == nil {
+		t.Error("Expected token invalid for other purpose")
+	}
+	if err := helpers.VerifyUserToken(helpers.TokenResetPassword, token, "hash-1", now.Add(2*time.Hour)); err == nil {
+		t.Error("Expected token expired")
+	}
+	if err := helpers.VerifyUserToken(helpers.TokenResetPassword, "43"+token[2:], "hash-1", now); err == nil {
+		t.Error("Expected tampered token invalid")
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"encoding/json"
+	"go-blog/controllers"
+	"go-blog/helpers"
+	"go-blog/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	source := "# Intro\n\n## 安装步骤\n\n## Intro\n\n```go\nfunc main() {}\n```\n\n" +
+		"<script>alert(1)</script>\n\n<img src=\"/a.png\" onerror=\"alert(1)\">\n\n[link](javascript:alert(1))\n"
+	rendered := helpers.RenderMarkdown(source)
+
+	for _, unsafe := range []string{"<script", "onerror", "javascript:"} {
+		if strings.Contains(rendered.HTML, unsafe) {
+			t.Errorf("Expected %q removed: %s", unsafe, rendered.HTML)
+		}
+	}
+	if !strings.Contains(rendered.HTML, `<img src="/a.png"`) {
+		t.Errorf("Expected image kept: %s", rendered.HTML)
+	}
+	// 代码块服务端高亮
+	if !strings.Contains(rendered.HTML, `<span class="hl-kd">func</span>`) {
+		t.Errorf("Expected highlighted code: %s", rendered.HTML)
+	}
+	// 标题锚点与目录，相同标题的锚点依次编号
+	want := []helpers.TOCItem{
+		{Level: 1, ID: "intro", Title: "Intro"},
+		{Level: 2, ID: "安装步骤", Title: "安装步骤"},
+		{Level: 2, ID: "intro-1", Title: "Intro"},
+	}
+	if len(rendered.TOC) != len(want) {
+		t.Fatalf("Unexpected toc: %+v", rendered.TOC)
+	}
+	for i, item := range want {
+		if rendered.TOC[i] != item {
+			t.Errorf("toc[%d] = %+v, want %+v", i, rendered.TOC[i], item)
+		}
+	}
+	if !strings.Contains(rendered.HTML, `<h2 id="intro-1">`) {
+		t.Errorf("Expected heading anchor: %s", rendered.HTML)
+	}
+	if toc := helpers.ParseTOC(rendered.TOCJSON()); len(toc) != 3 {
+		t.Errorf("Unexpected parsed toc: %+v", toc)
+	}
+}
+
+func TestRenderedContentInvalidation(t *testing.T) {
+	setupTestDB()
+	post := &models.Post{Title: "rendered", Content: "# old", UserID: 1}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	post.ContentHTML, post.TOC = "<h1>old</h1>", "[]"
+	if err := post.SaveRendered(); err != nil {
+		t.Fatalf("SaveRendered err: %v", err)
+	}
+	saved, _ := models.GetAnyPostById(post.ID)
+	if saved.ContentHTML != "<h1>old</h1>" {
+		t.Fatalf("Expected rendered html stored, got %q", saved.ContentHTML)
+	}
+
+	post.Content = "# new"
+	if err := post.Update(); err != nil {
+		t.Fatalf("Update err: %v", err)
+	}
+	if saved, _ = models.GetAnyPostById(post.ID); saved.ContentHTML != "" || saved.TOC != "" {
+		t.Errorf("Expected rendered html cleared, got %q", saved.ContentHTML)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/admin/preview", controllers.Preview)
+
+	form := url.Values{"content": {"## Title\n\n<script>alert(1)</script>"}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/preview", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var res struct {
+		Succeed bool
+		HTML    string
+		TOC     []helpers.TOCItem
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || !res.Succeed {
+		t.Fatalf("Unexpected response: %s", w.Body.String())
+	}
+	if res.HTML != helpers.RenderMarkdown(form.Get("content")).HTML || strings.Contains(res.HTML, "<script") || len(res.TOC) != 1 {
+		t.Errorf("Unexpected preview: %+v", res)
+	}
+}
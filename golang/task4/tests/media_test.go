@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-blog/controllers"
+	"go-blog/models"
+	"go-blog/system"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMediaLibrary(t *testing.T) {
+	db := setupTestDB()
+	db.Exec("DELETE FROM post_attachments")
+	db.Exec("DELETE FROM attachments")
+
+	conf := filepath.Join(t.TempDir(), "conf.toml")
+	if err := os.WriteFile(conf, []byte("[upload]\norphan_grace_seconds = 3600\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.LoadConfiguration(conf); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+	defer system.LoadConfiguration("../conf/conf.toml")
+	controllers.SetUploader(controllers.NewMemoryUploader("/upload/"))
+	defer controllers.SetUploader(nil)
+
+	user := &models.User{Username: "media-owner", Email: "media-owner@example.org", Role: models.RoleAuthor}
+	db.Unscoped().Where("username = ?", user.Username).Delete(&models.User{})
+	if err := user.Insert(); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(controllers.ContextUserKey, user)
+	})
+	router.POST("/admin/upload", controllers.Upload)
+	router.GET("/api/v1/attachments", controllers.APIAttachmentList)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "photo.png")
+	part.Write(pngData)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/admin/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var uploaded struct {
+		Succeed bool
+		ID      uint
+		URL     string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &uploaded); err != nil || !uploaded.Succeed {
+		t.Fatalf("Unexpected upload response: %s", w.Body.String())
+	}
+	attachment, err := models.GetAttachment(uploaded.ID)
+	if err != nil || attachment.Filename != "photo.png" || attachment.UserID != user.ID || attachment.RefCount != 0 {
+		t.Fatalf("Unexpected attachment: %+v %v", attachment, err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/attachments", nil))
+	var list models.PageResponse[models.AttachmentData]
+	if err = json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.Total != 1 || list.Payload[0].URL != uploaded.URL {
+		t.Fatalf("Unexpected list response: %s", w.Body.String())
+	}
+
+	// 引用式链接同样计入引用
+	post := &models.Post{Title: "media", Content: "![photo][1]\n\n[1]: " + uploaded.URL + " \"photo\"", UserID: user.ID}
+	if err = post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	if err = post.SaveRevision(user.ID); err != nil {
+		t.Fatalf("SaveRevision err: %v", err)
+	}
+	if attachment, _ = models.GetAttachment(uploaded.ID); attachment.RefCount != 1 {
+		t.Fatalf("Expected 1 reference, got %d", attachment.RefCount)
+	}
+	// 其他用户重复上传得到原记录，仍被引用时不改变保留期
+	again, err := models.SaveAttachment(&models.Attachment{UserID: user.ID + 1, Hash: attachment.Hash, Key: attachment.Key, URL: attachment.URL})
+	if err != nil || again.ID != attachment.ID || again.UserID != user.ID || again.DetachedAt != nil {
+		t.Errorf("Unexpected re-uploaded attachment: %+v %v", again, err)
+	}
+	if posts, _ := attachment.ReferencedPosts(); len(posts) != 1 || posts[0].ID != post.ID {
+		t.Errorf("Unexpected referenced posts: %v", posts)
+	}
+	if err = attachment.Purge(); err != models.ErrAttachmentInUse {
+		t.Errorf("Expected ErrAttachmentInUse, got %v", err)
+	}
+
+	now := time.Now()
+	if count, _ := controllers.CollectOrphanAttachments(now.Add(2 * time.Hour)); count != 0 {
+		t.Fatalf("Expected referenced attachment kept, removed %d", count)
+	}
+
+	// 移除引用后从此时开始计算保留期
+	post.Content = "no images"
+	if err = post.Update(); err != nil {
+		t.Fatalf("Update err: %v", err)
+	}
+	if err = post.SaveRevision(user.ID); err != nil {
+		t.Fatalf("SaveRevision err: %v", err)
+	}
+	if count, _ := controllers.CollectOrphanAttachments(now.Add(30 * time.Minute)); count != 0 {
+		t.Fatalf("Expected attachment kept within grace period, removed %d", count)
+	}
+	if count, _ := controllers.CollectOrphanAttachments(now.Add(2 * time.Hour)); count != 1 {
+		t.Fatalf("Expected orphan removed, removed %d", count)
+	}
+	if _, err = models.GetAttachment(uploaded.ID); err == nil {
+		t.Error("Expected attachment record deleted")
+	}
+
+	// 恢复引用已清理附件的旧版本时，列出失效的地址
+	revisions, _ := models.ListRevisionByPostID(post.ID)
+	if err = post.RestoreRevision(revisions[len(revisions)-1], user.ID); err != nil {
+		t.Fatalf("RestoreRevision err: %v", err)
+	}
+	if missing, err := models.MissingAttachmentURLs(post.Content); err != nil || len(missing) != 1 || missing[0] != uploaded.URL {
+		t.Errorf("Expected missing attachment reported, got %v %v", missing, err)
+	}
+}
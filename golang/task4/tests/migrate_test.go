@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"errors"
+	"go-blog/models"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	latest := models.LatestSchemaVersion()
+	if err = models.CheckSchema(db); !errors.Is(err, models.ErrSchemaOutdated) {
+		t.Errorf("Expected empty database outdated, got %v", err)
+	}
+
+	executed, err := models.MigrateUp(db)
+	if err != nil || len(executed) != latest {
+		t.Fatalf("MigrateUp = %d, %v", len(executed), err)
+	}
+	if err = models.CheckSchema(db); err != nil {
+		t.Errorf("CheckSchema err: %v", err)
+	}
+	// 迁移建出的表包含模型的全部字段
+	for _, model := range currentModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err = stmt.Parse(model); err != nil {
+			t.Fatalf("Parse model err: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if len(field.DBName) > 0 && !field.IgnoreMigration && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("Expected column %s.%s created", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+	// 全文检索触发器引用了部分字段，回滚时需先删除触发器
+	_ = models.InitSearch(db)
+	if executed, _ = models.MigrateUp(db); len(executed) != 0 {
+		t.Errorf("Expected no pending migrations, got %d", len(executed))
+	}
+
+	// 逐个回滚到空库
+	for version := latest; version > 0; version-- {
+		m, err := models.MigrateDown(db)
+		if err != nil || m == nil || m.Version != version {
+			t.Fatalf("MigrateDown = %v, %v", m, err)
+		}
+	}
+	if db.Migrator().HasTable(&models.Post{}) {
+		t.Errorf("Expected posts table dropped")
+	}
+	if m, err := models.MigrateDown(db); m != nil || err != nil {
+		t.Errorf("Expected nothing to roll back, got %v, %v", m, err)
+	}
+
+	// 数据库由更新的程序迁移过时拒绝执行
+	if _, err = models.MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp err: %v", err)
+	}
+	db.Create(&models.SchemaMigration{Version: latest + 1, Name: "future"})
+	if err = models.CheckSchema(db); !errors.Is(err, models.ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+	if _, err = models.MigrateUp(db); !errors.Is(err, models.ErrSchemaTooNew) {
+		t.Errorf("Expected MigrateUp refused, got %v", err)
+	}
+}
+
+func currentModels() []interface{} {
+	return []interface{}{&models.User{}, &models.Post{}, &models.Comment{}, &models.Tag{}, &models.Category{}, &models.PostRevision{},
+		&models.LoginFailure{}, &models.LockoutEvent{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.ProviderIdentity{},
+		&models.SiweNonce{}, &models.Attachment{}, &models.PostView{}}
+}
+
+// 原先由 AutoMigrate 创建的数据库可直接执行全部迁移，并补全历史文章的发布时间
+func TestMigrateFromAutoMigrate(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "legacy.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open db: %v", err)
+	}
+	if err = db.AutoMigrate(currentModels()...); err != nil {
+		t.Fatalf("AutoMigrate err: %v", err)
+	}
+	db.Exec("INSERT INTO posts (title, content, status, created_at) VALUES ('legacy', 'legacy', 'published', '2020-01-02 00:00:00')")
+
+	if executed, err := models.MigrateUp(db); err != nil || len(executed) != models.LatestSchemaVersion() {
+		t.Fatalf("MigrateUp = %d, %v", len(executed), err)
+	}
+	var publishedAt *string
+	db.Raw("SELECT published_at FROM posts WHERE title = 'legacy'").Scan(&publishedAt)
+	if publishedAt == nil {
+		t.Error("Expected published_at backfilled")
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"go-blog/controllers"
+	"go-blog/models"
+	"go-blog/system"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+// 模拟 GitHub 的授权服务与用户接口
+func githubStub() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("client_secret") != "secret" || r.PostFormValue("code") != "good-code" {
+			fmt.Fprint(w, `{"error":"bad_verification_code"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"stub-token","token_type":"bearer"}`)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"id":583231,"login":"octocat","email":null,"avatar_url":"https://example.org/octocat.png"}`)
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"email":"old@example.org","primary":false,"verified":true},{"email":"octocat@example.org","primary":true,"verified":true}]`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestOAuthLogin(t *testing.T) {
+	db := setupTestDB()
+	db.Exec("DELETE FROM provider_identities WHERE provider = ?", "github")
+	db.Exec("DELETE FROM users WHERE username LIKE ? OR email = ?", "octocat%", "octocat@example.org")
+
+	stub := githubStub()
+	defer stub.Close()
+
+	conf := filepath.Join(t.TempDir(), "conf.toml")
+	data := fmt.Sprintf(`domain = 'http://blog.test'
+
+[[oauth]]
+name = 'github'
+type = 'github'
+client_id = 'cid'
+client_secret = 'secret'
+auth_url = '%[1]s/login/oauth/authorize'
+token_url = '%[1]s/login/oauth/access_token'
+userinfo_url = '%[1]s/user'
+emails_url = '%[1]s/user/emails'
+`, stub.URL)
+	if err := os.WriteFile(conf, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.LoadConfiguration(conf); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+	defer system.LoadConfiguration("../conf/conf.toml")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.SetHTMLTemplate(template.Must(template.New("errors/error.html").Parse("{{.message}}")))
+	router.Use(sessions.Sessions("blog-session", cookie.NewStore([]byte("test-secret"))))
+	router.GET("/oauth/:provider", controllers.OAuthLogin)
+	router.GET("/oauth/:provider/callback", controllers.OAuthCallback)
+
+	// 跳转到授权页面，state 保存在 session 中
+	authorize := func() (string, []*http.Cookie) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth/github", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected 302, got %d", w.Code)
+		}
+		location, _ := url.Parse(w.Header().Get("Location"))
+		query := location.Query()
+		if query.Get("client_id") != "cid" || query.Get("redirect_uri") != "http://blog.test/oauth/github/callback" {
+			t.Fatalf("Unexpected authorize url: %s", location)
+		}
+		return query.Get("state"), w.Result().Cookies()
+	}
+	callback := func(code, state string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/oauth/github/callback?code="+code+"&state="+url.QueryEscape(state), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	state, cookies := authorize()
+	w := callback("good-code", "forged", cookies)
+	if w.Code == http.StatusFound {
+		t.Fatal("Expected forged state rejected")
+	}
+	// state 校验失败后即从 session 中删除
+	if w = callback("good-code", state, w.Result().Cookies()); w.Code == http.StatusFound {
+		t.Fatal("Expected used state rejected")
+	}
+
+	state, cookies = authorize()
+	if w := callback("bad-code", state, cookies); w.Code == http.StatusFound {
+		t.Fatal("Expected bad code rejected")
+	}
+
+	state, cookies = authorize()
+	if w := callback("good-code", state, cookies); w.Code != http.StatusFound || w.Header().Get("Location") != "/" {
+		t.Fatalf("Expected login redirect, got %d %s", w.Code, w.Body.String())
+	}
+	identity, err := models.GetProviderIdentity("github", "583231")
+	if err != nil {
+		t.Fatalf("GetProviderIdentity err: %v", err)
+	}
+	user, _ := models.GetUser(identity.UserID)
+	if user.Username != "octocat" || user.Email != "octocat@example.org" || !user.IsEmailVerified() {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+
+	// 再次登录使用已绑定的用户
+	state, cookies = authorize()
+	callback("good-code", state, cookies)
+	var count int64
+	db.Model(&models.User{}).Where("username LIKE ?", "octocat%").Count(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+}
@@ -45,3 +45,73 @@ func TestInsertPost(t *testing.T) {
 		t.Errorf("Expected title 'Go Concurrency Patterns', got '%s'", p.Title)
 	}
 }
+
+func TestListPostPagination(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	var posts []*models.Post
+	for i := 0; i < 5; i++ {
+		post := &models.Post{Title: "page post", Content: "content"}
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		posts = append(posts, post)
+	}
+	// 逻辑删除的文章不参与计数与列表
+	if err := posts[4].LogicDelete(); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+
+	if total, _ := models.CountPost(); total != 4 {
+		t.Errorf("Expected 4 posts, got %d", total)
+	}
+	page, err := models.ListPost(2, 3)
+	if err != nil || len(page) != 1 {
+		t.Fatalf("Expected 1 post on page 2, got %d (%v)", len(page), err)
+	}
+	if page[0].ID != posts[0].ID {
+		t.Errorf("Expected oldest post on last page, got ID %d", page[0].ID)
+	}
+
+	first, _ := models.ListPostAfter(0, 2)
+	if len(first) != 2 || first[0].ID != posts[3].ID {
+		t.Fatalf("Expected keyset page to start at newest visible post, got %v", first)
+	}
+	next, _ := models.ListPostAfter(first[1].ID, 2)
+	if len(next) != 2 || next[0].ID != posts[1].ID || next[1].ID != posts[0].ID {
+		t.Errorf("Expected keyset page to continue after cursor, got %v", next)
+	}
+}
+
+func TestListPostByQuery(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM post_tags")
+	db.Exec("DELETE FROM posts")
+
+	tags, _ := models.GetOrCreateTags([]string{"query"})
+	posts := []*models.Post{
+		{Title: "Gin middleware", Content: "content", UserID: 1, Tags: tags},
+		{Title: "Gorm hooks", Content: "content", UserID: 1},
+		{Title: "Gin routing", Content: "content", UserID: 2},
+	}
+	for _, post := range posts {
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+
+	if total, _ := models.CountPostByQuery(models.PostQuery{UserID: 1}); total != 2 {
+		t.Errorf("Expected 2 posts of user 1, got %d", total)
+	}
+	if total, _ := models.CountPostByQuery(models.PostQuery{Keyword: "Gin"}); total != 2 {
+		t.Errorf("Expected 2 posts matching 'Gin', got %d", total)
+	}
+	result, err := models.ListPostByQuery(models.PostQuery{UserID: 1, Tag: "query"}, 1, 10)
+	if err != nil || len(result) != 1 || result[0].ID != posts[0].ID {
+		t.Fatalf("Expected tagged post of user 1, got %v (%v)", result, err)
+	}
+}
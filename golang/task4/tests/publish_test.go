@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"go-blog/models"
+	"testing"
+	"time"
+)
+
+func TestDraftPostHidden(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	published := &models.Post{Title: "published post", Content: "content"}
+	draft := &models.Post{Title: "draft post", Content: "content", Status: models.PostStatusDraft}
+	for _, post := range []*models.Post{published, draft} {
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+	if published.Status != models.PostStatusPublished || published.PublishedAt == nil {
+		t.Errorf("Expected new post to be published, got %q", published.Status)
+	}
+
+	count, err := models.CountPost()
+	if err != nil {
+		t.Fatalf("CountPost err: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 published post, got %d", count)
+	}
+	if _, err = models.GetPostById(draft.ID); err == nil {
+		t.Error("Expected draft post to be hidden from GetPostById")
+	}
+	if _, err = models.GetAnyPostById(draft.ID); err != nil {
+		t.Errorf("Expected draft post to be visible to author: %v", err)
+	}
+
+	if err = draft.Publish(); err != nil {
+		t.Fatalf("Publish err: %v", err)
+	}
+	if _, err = models.GetPostById(draft.ID); err != nil {
+		t.Errorf("Expected published draft to be visible: %v", err)
+	}
+}
+
+func TestPublishDuePosts(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	post := &models.Post{Title: "scheduled post", Content: "content"}
+	if err := post.SetStatus(models.PostStatusScheduled, nil); err != models.ErrPublishTimeRequired {
+		t.Errorf("Expected ErrPublishTimeRequired, got %v", err)
+	}
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := post.SetStatus(models.PostStatusScheduled, &publishAt); err != nil {
+		t.Fatalf("SetStatus err: %v", err)
+	}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+
+	next, err := models.NextScheduledAt()
+	if err != nil || next == nil || !next.Equal(publishAt) {
+		t.Fatalf("Expected next scheduled at %v, got %v (%v)", publishAt, next, err)
+	}
+
+	count, _ := models.PublishDuePosts(time.Now())
+	if count != 0 {
+		t.Errorf("Expected no post to be published yet, got %d", count)
+	}
+	count, _ = models.PublishDuePosts(publishAt.Add(time.Minute))
+	if count != 1 {
+		t.Errorf("Expected 1 post to be published, got %d", count)
+	}
+	if _, err = models.GetPostById(post.ID); err != nil {
+		t.Errorf("Expected scheduled post to be visible after publish: %v", err)
+	}
+}
+
+func TestSetStatusKeepsCurrent(t *testing.T) {
+	// 未指定状态时草稿保持草稿
+	draft := &models.Post{Title: "draft", Status: models.PostStatusDraft}
+	if err := draft.SetStatus("", nil); err != nil || draft.Status != models.PostStatusDraft || draft.PublishedAt != nil {
+		t.Errorf("Expected draft kept, got %s %v (%v)", draft.Status, draft.PublishedAt, err)
+	}
+
+	// 定时发布的文章只修改发布时间
+	publishAt := time.Now().Add(time.Hour)
+	scheduled := &models.Post{Title: "scheduled", Status: models.PostStatusScheduled}
+	if err := scheduled.SetStatus("", &publishAt); err != nil || scheduled.Status != models.PostStatusScheduled || !scheduled.PublishedAt.Equal(publishAt) {
+		t.Errorf("Expected schedule moved, got %s %v (%v)", scheduled.Status, scheduled.PublishedAt, err)
+	}
+
+	// 缺少发布时间的历史文章以创建时间补全
+	legacy := &models.Post{Title: "legacy", Status: models.PostStatusPublished}
+	legacy.CreatedAt = time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := legacy.SetStatus("", nil); err != nil || legacy.PublishedAt == nil || !legacy.PublishedAt.Equal(legacy.CreatedAt) {
+		t.Errorf("Expected published_at backfilled, got %v (%v)", legacy.PublishedAt, err)
+	}
+
+	// 新文章默认发布
+	post := &models.Post{Title: "new"}
+	if err := post.SetStatus("", nil); err != nil || post.Status != models.PostStatusPublished {
+		t.Errorf("Expected new post published, got %s (%v)", post.Status, err)
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPostRevisionRestore(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM post_revisions")
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	post := &models.Post{Title: "first title", Content: "line 1\nline 2", UserID: 1}
+	if err := post.Insert(); err != nil {
+		t.Fatalf("Failed to insert post: %v", err)
+	}
+	// 历史文章首次修改时补录初始版本
+	if err := post.EnsureRevision(); err != nil {
+		t.Fatalf("EnsureRevision err: %v", err)
+	}
+	if err := post.EnsureRevision(); err != nil {
+		t.Fatalf("EnsureRevision err: %v", err)
+	}
+
+	post.Title = "second title"
+	post.Content = "line 1\nline 2 changed\nline 3"
+	if err := post.Update(); err != nil {
+		t.Fatalf("Update err: %v", err)
+	}
+	if err := post.SaveRevision(2); err != nil {
+		t.Fatalf("SaveRevision err: %v", err)
+	}
+
+	revisions, err := models.ListRevisionByPostID(post.ID)
+	if err != nil {
+		t.Fatalf("ListRevisionByPostID err: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+	first := revisions[1]
+	if first.Title != "first title" || first.UserID != 1 {
+		t.Errorf("Expected first revision to keep original content, got %q by %d", first.Title, first.UserID)
+	}
+
+	if err = post.RestoreRevision(first, 2); err != nil {
+		t.Fatalf("RestoreRevision err: %v", err)
+	}
+	restored, _ := models.GetAnyPostById(post.ID)
+	if restored.Title != "first title" || restored.Content != "line 1\nline 2" {
+		t.Errorf("Expected post to be restored, got %q", restored.Title)
+	}
+	revisions, _ = models.ListRevisionByPostID(post.ID)
+	if len(revisions) != 3 || revisions[0].Title != "first title" {
+		t.Errorf("Expected restore to create a new revision, got %d revisions", len(revisions))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	lines := helpers.DiffLines("a\nb\nc", "a\nc\nd")
+	var ops string
+	for _, line := range lines {
+		ops += line.Op + line.Text + ";"
+	}
+	if ops != " a;-b; c;+d;" {
+		t.Errorf("Unexpected diff: %s", ops)
+	}
+
+	// 长文本只改动一行，不再按行数的平方分配内存
+	text := make([]string, 50000)
+	for i := range text {
+		text[i] = strconv.Itoa(i)
+	}
+	oldText := strings.Join(text, "\n")
+	text[25000] = "changed"
+	lines = helpers.DiffLines(oldText, strings.Join(text, "\n"))
+	if len(lines) != 50001 || lines[25000].Op != helpers.DiffDelete || lines[25001].Op != helpers.DiffInsert || lines[25001].NewLine != 25001 {
+		t.Errorf("Unexpected diff of long text: %d lines", len(lines))
+	}
+}
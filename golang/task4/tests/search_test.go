@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"go-blog/helpers"
+	"go-blog/models"
+	"strings"
+	"testing"
+)
+
+func TestSearchPost(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM comments")
+	db.Exec("DELETE FROM posts")
+
+	goPost := &models.Post{Title: "Go Concurrency Patterns", Content: "Goroutines and channels make concurrency simple."}
+	rustPost := &models.Post{Title: "Rust Ownership", Content: "Borrowing rules explained."}
+	for _, post := range []*models.Post{goPost, rustPost} {
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+	comment := &models.Comment{PostID: rustPost.ID, Content: "Compared with goroutines?"}
+	if err := comment.Insert(); err != nil {
+		t.Fatalf("Failed to insert comment: %v", err)
+	}
+
+	results, err := models.SearchPost("goroutines", 1, 10)
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results (post and comment match), got %d", len(results))
+	}
+	if total, _ := models.CountSearchPost("goroutines"); total != 2 {
+		t.Errorf("Expected total 2, got %d", total)
+	}
+
+	results, _ = models.SearchPost("concurrency", 1, 10)
+	if len(results) != 1 || results[0].PostID != goPost.ID {
+		t.Fatalf("Expected go post only, got %v", results)
+	}
+	title := string(helpers.Highlight(results[0].Title))
+	if !strings.Contains(title, "<mark>Concurrency</mark>") {
+		t.Errorf("Expected highlighted title, got '%s'", title)
+	}
+
+	// 逻辑删除后不再出现在检索结果中
+	if err = goPost.LogicDelete(); err != nil {
+		t.Fatalf("Failed to delete post: %v", err)
+	}
+	if total, _ := models.CountSearchPost("concurrency"); total != 0 {
+		t.Errorf("Expected deleted post to be excluded, got %d", total)
+	}
+}
+
+func TestHighlightEscapesContent(t *testing.T) {
+	source := "<script>" + models.HighlightOpen + "alert" + models.HighlightClose + "</script>"
+	expected := "&lt;script&gt;<mark>alert</mark>&lt;/script&gt;"
+	if got := string(helpers.Highlight(source)); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+}
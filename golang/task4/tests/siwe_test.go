@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-blog/controllers"
+	"go-blog/helpers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+const siweMessage = `example.com wants you to sign in with your Ethereum account:
+0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2
+
+I accept the Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756abcd
+Issued At: 2021-09-30T16:25:24Z
+Expiration Time: 2021-10-01T16:25:24Z
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func TestParseSiweMessage(t *testing.T) {
+	msg, err := helpers.ParseSiweMessage(siweMessage)
+	if err != nil {
+		t.Fatalf("ParseSiweMessage err: %v", err)
+	}
+	if msg.Domain != "example.com" || msg.ChainID != 1 || msg.Nonce != "32891756abcd" || len(msg.Resources) != 2 || msg.ExpirationTime == nil {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if msg.String() != siweMessage {
+		t.Errorf("String() = %q", msg.String())
+	}
+	rules := helpers.SiweRules{Domain: "example.com", ChainIDs: []int64{1}, MaxAge: 10 * time.Minute, Skew: time.Minute}
+	if err = msg.Valid(rules, msg.IssuedAt); err != nil {
+		t.Errorf("Expected message valid, got %v", err)
+	}
+	if err = msg.Valid(helpers.SiweRules{Domain: "example.com", ChainIDs: []int64{1}}, msg.ExpirationTime.Add(time.Second)); err == nil {
+		t.Error("Expected expired message invalid")
+	}
+	if err = msg.Valid(helpers.SiweRules{Domain: "evil.com", ChainIDs: []int64{1}}, msg.IssuedAt); err == nil {
+		t.Error("Expected other domain invalid")
+	}
+	if err = msg.Valid(helpers.SiweRules{Domain: "example.com", ChainIDs: []int64{5}}, msg.IssuedAt); err == nil {
+		t.Error("Expected other chain invalid")
+	}
+	// 签发时间超出时钟偏差或早于随机数有效期
+	if err = msg.Valid(rules, msg.IssuedAt.Add(-2*time.Minute)); err == nil {
+		t.Error("Expected message issued in the future invalid")
+	}
+	if err = msg.Valid(rules, msg.IssuedAt.Add(time.Hour)); err == nil {
+		t.Error("Expected stale message invalid")
+	}
+
+	// 地址必须为 EIP-55 校验和格式
+	lower := strings.Replace(siweMessage, "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", 1)
+	if _, err = helpers.ParseSiweMessage(lower); err == nil {
+		t.Error("Expected non-checksummed address rejected")
+	}
+}
+
+func TestSiweLogin(t *testing.T) {
+	db := setupTestDB()
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	db.Exec("DELETE FROM users WHERE username = ?", address)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("blog-session", cookie.NewStore([]byte("test-secret"))))
+	router.GET("/siwe/nonce", controllers.SiweNonceGet)
+	router.POST("/siwe/verify", controllers.SiwePost)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/siwe/nonce?address="+strings.ToLower(address), nil))
+	var nonce struct{ Nonce, Message string }
+	if err := json.Unmarshal(w.Body.Bytes(), &nonce); err != nil || len(nonce.Message) == 0 {
+		t.Fatalf("Unexpected nonce response: %s", w.Body.String())
+	}
+	if recovered, err := helpers.ParseSiweMessage(nonce.Message); err != nil || recovered.Address != address {
+		t.Fatalf("Unexpected message: %v %s", err, nonce.Message)
+	}
+
+	// personal_sign：钱包返回的 v 为 27/28
+	sig, err := crypto.Sign(crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(nonce.Message), nonce.Message))), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+	signature := hexutil.Encode(sig)
+	if recovered, err := helpers.RecoverPersonalSign(nonce.Message, signature); err != nil || recovered.Hex() != address {
+		t.Fatalf("RecoverPersonalSign = %s, %v", recovered.Hex(), err)
+	}
+
+	verify := func(message, signature string) int {
+		form := url.Values{"message": {message}, "signature": {signature}}
+		req := httptest.NewRequest(http.MethodPost, "/siwe/verify", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	// 篡改消息后签名无效，随机数不会被使用
+	if code := verify(strings.Replace(nonce.Message, "Chain ID: 1", "Chain ID: 5", 1), signature); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for tampered message, got %d", code)
+	}
+	if code := verify(nonce.Message, signature); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	identity, err := models.GetProviderIdentity(models.ProviderEthereum, address)
+	if err != nil {
+		t.Fatalf("GetProviderIdentity err: %v", err)
+	}
+	if user, _ := models.GetUser(identity.UserID); user.Username != address {
+		t.Errorf("Unexpected user: %+v", user)
+	}
+	// 随机数只能使用一次
+	if code := verify(nonce.Message, signature); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for replayed nonce, got %d", code)
+	}
+
+	// 域名只认配置中的 domain，其他站点的签名即使 Host 匹配也无效
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/siwe/nonce", nil))
+	json.Unmarshal(w.Body.Bytes(), &nonce)
+	now := time.Now().UTC().Truncate(time.Second)
+	phished := (&helpers.SiweMessage{
+		Domain: "example.com", Address: address, URI: "https://example.com", Version: "1",
+		ChainID: 1, Nonce: nonce.Nonce, IssuedAt: now,
+	}).String()
+	sig, _ = crypto.Sign(crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(phished), phished))), key)
+	sig[64] += 27
+	if code := verify(phished, hexutil.Encode(sig)); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for message bound to request host, got %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/siwe/nonce?chain_id=5", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for chain not allowed, got %d", w.Code)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := helpers.NewRateLimiter(2, time.Minute)
+	now := time.Now()
+	if !limiter.Allow("ip", now) || !limiter.Allow("ip", now) || limiter.Allow("ip", now) {
+		t.Error("Expected third request in window limited")
+	}
+	if !limiter.Allow("other", now) || !limiter.Allow("ip", now.Add(time.Minute)) {
+		t.Error("Expected other keys and next window allowed")
+	}
+}
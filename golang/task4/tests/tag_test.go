@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"go-blog/models"
+	"testing"
+)
+
+func TestSplitNamesAndSlugify(t *testing.T) {
+	names := models.SplitNames(" Go, gin ，Go,, ")
+	if len(names) != 2 || names[0] != "Go" || names[1] != "gin" {
+		t.Errorf("Expected [Go gin], got %v", names)
+	}
+	if slug := models.Slugify("Web3 & Go 开发"); slug != "web3-go-开发" {
+		t.Errorf("Expected slug 'web3-go-开发', got '%s'", slug)
+	}
+}
+
+func TestListPostByTagAndCategory(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM post_tags")
+	db.Exec("DELETE FROM post_categories")
+	db.Exec("DELETE FROM tags")
+	db.Exec("DELETE FROM categories")
+	db.Exec("DELETE FROM posts")
+
+	tags, err := models.GetOrCreateTags([]string{"golang", "gin"})
+	if err != nil {
+		t.Fatalf("Failed to create tags: %v", err)
+	}
+	categories, err := models.GetOrCreateCategories([]string{"Back End"})
+	if err != nil {
+		t.Fatalf("Failed to create categories: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		post := &models.Post{Title: "post", Content: "content", Tags: tags[:1]}
+		if i == 0 {
+			post.Tags = tags
+			post.Categories = categories
+		}
+		if err = post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+	}
+
+	posts, err := models.ListPostByTag("golang", 1, 2)
+	if err != nil || len(posts) != 2 {
+		t.Fatalf("Expected 2 posts on first page, got %d (%v)", len(posts), err)
+	}
+	if total, _ := models.CountPostByTag("golang"); total != 3 {
+		t.Errorf("Expected 3 posts tagged golang, got %d", total)
+	}
+	if total, _ := models.CountPostByTag("gin"); total != 1 {
+		t.Errorf("Expected 1 post tagged gin, got %d", total)
+	}
+
+	posts, err = models.ListPostByCategory("back-end", 1, 10)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("Expected 1 post in category, got %d (%v)", len(posts), err)
+	}
+	if posts[0].TagNames() != "golang,gin" && posts[0].TagNames() != "gin,golang" {
+		t.Errorf("Expected tags golang,gin, got '%s'", posts[0].TagNames())
+	}
+
+	// 更新标签后旧关联被替换
+	if err = posts[0].ReplaceTags(tags[1:]); err != nil {
+		t.Fatalf("Failed to replace tags: %v", err)
+	}
+	if total, _ := models.CountPostByTag("golang"); total != 2 {
+		t.Errorf("Expected 2 posts tagged golang after replace, got %d", total)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"go-blog/models"
 	"go-blog/system"
 	"log"
+	"os"
 	"path/filepath"
 
 	"gorm.io/driver/sqlite"
@@ -19,10 +20,20 @@ func setupTestDB() *gorm.DB {
 	if err != nil {
 		log.Fatal("Failed to get absolute path:", err)
 	}
-	db, err := gorm.Open(sqlite.Open(filepath.Join(testDir, "..", "db", "personal_blog.db")), &gorm.Config{})
+	dbDir := filepath.Join(testDir, "..", "db")
+	if err = os.MkdirAll(dbDir, os.ModePerm); err != nil {
+		log.Fatal("Failed to create db dir:", err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dbDir, "personal_blog.db")), &gorm.Config{})
 	if err != nil {
 		panic(err)
 	}
-	db.AutoMigrate(&models.User{})
+	if _, err = models.MigrateUp(db); err != nil {
+		log.Fatal("Failed to migrate:", err)
+	}
+	// FTS5 需以 -tags sqlite_fts5 编译，否则检索退化为 LIKE 匹配
+	_ = models.InitSearch(db)
+	_ = models.RegisterChangeCallbacks(db)
+	models.DB = db
 	return db
 }
@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"fmt"
+	"go-blog/controllers"
+	"go-blog/helpers"
+	"go-blog/models"
+	"go-blog/system"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM refresh_tokens")
+
+	const userID = 201
+	raw, _, err := models.IssueRefreshToken(userID, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("IssueRefreshToken err: %v", err)
+	}
+	rotated, token, err := models.RotateRefreshToken(raw, "test", "127.0.0.1")
+	if err != nil || token.UserID != userID {
+		t.Fatalf("RotateRefreshToken err: %v", err)
+	}
+
+	// 旧令牌再次使用视为泄露，同一次登录的令牌全部吊销
+	if _, _, err = models.RotateRefreshToken(raw, "test", "127.0.0.1"); err != models.ErrRefreshTokenReused {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, _, err = models.RotateRefreshToken(rotated, "test", "127.0.0.1"); err != models.ErrRefreshTokenReused {
+		t.Errorf("Expected rotated token revoked, got %v", err)
+	}
+	if _, _, err = models.RotateRefreshToken("unknown", "test", "127.0.0.1"); err != models.ErrRefreshTokenInvalid {
+		t.Errorf("Expected ErrRefreshTokenInvalid, got %v", err)
+	}
+
+	// 并发轮换同一个令牌时只有一个请求成功，其余视为重复使用
+	raw, _, _ = models.IssueRefreshToken(userID, "test", "127.0.0.1")
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		succeed int
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := models.RotateRefreshToken(raw, "test", "127.0.0.1"); err == nil {
+				mu.Lock()
+				succeed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if succeed > 1 {
+		t.Errorf("Expected at most one rotation to succeed, got %d", succeed)
+	}
+}
+
+func TestTokenRevocation(t *testing.T) {
+	db := setupTestDB()
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db.Exec("DELETE FROM users")
+	db.Exec("DELETE FROM refresh_tokens")
+	db.Exec("DELETE FROM revoked_tokens")
+
+	user := &models.User{Username: "token-user", Password: "password", Email: "token@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	newClaims := func() *helpers.MyClaims {
+		tokenString, err := helpers.GenerateToken(*user, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("GenerateToken err: %v", err)
+		}
+		claims, err := helpers.ParseToken(tokenString)
+		if err != nil {
+			t.Fatalf("ParseToken err: %v", err)
+		}
+		return claims
+	}
+
+	claims := newClaims()
+	if err := helpers.CheckRevoked(claims); err != nil {
+		t.Fatalf("Expected token valid, got %v", err)
+	}
+	if err := helpers.RevokeClaims(claims); err != nil {
+		t.Fatalf("RevokeClaims err: %v", err)
+	}
+	if err := helpers.CheckRevoked(claims); err != models.ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	// 退出所有设备后，之前签发的令牌全部失效
+	claims = newClaims()
+	raw, _, _ := models.IssueRefreshToken(user.ID, "test", "127.0.0.1")
+	if err := user.LogoutEverywhere(); err != nil {
+		t.Fatalf("LogoutEverywhere err: %v", err)
+	}
+	if err := helpers.CheckRevoked(claims); err != models.ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked after logout everywhere, got %v", err)
+	}
+	if _, _, err := models.RotateRefreshToken(raw, "test", "127.0.0.1"); err == nil {
+		t.Errorf("Expected refresh token revoked after logout everywhere")
+	}
+	if err := helpers.CheckRevoked(newClaims()); err != nil {
+		t.Errorf("Expected new token valid, got %v", err)
+	}
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	setupTestDB()
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	defer helpers.LoadJWTKeys(system.JWT{})
+
+	dir := t.TempDir()
+	keys := make([]system.JWTKey, 0, 2)
+	for _, algorithm := range []string{"RS256", "EdDSA"} {
+		kid, err := helpers.GenerateJWTKey(algorithm, dir)
+		if err != nil {
+			t.Fatalf("GenerateJWTKey err: %v", err)
+		}
+		keys = append(keys, system.JWTKey{
+			Kid:        kid,
+			Algorithm:  algorithm,
+			PrivateKey: filepath.Join(dir, "jwt-"+kid+".pem"),
+		})
+	}
+
+	user := models.User{Username: "rotation"}
+	cfg := system.JWT{SigningKid: keys[0].Kid, Keys: keys}
+	if err := helpers.LoadJWTKeys(cfg); err != nil {
+		t.Fatalf("LoadJWTKeys err: %v", err)
+	}
+	oldToken, err := helpers.GenerateToken(user, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GenerateToken err: %v", err)
+	}
+	if jwks := helpers.JWKS()["keys"].([]map[string]string); len(jwks) != 2 {
+		t.Fatalf("Expected 2 keys in jwks, got %d", len(jwks))
+	}
+
+	// 轮换后旧密钥签发的 token 仍然有效，直至旧密钥被移除
+	cfg.SigningKid = keys[1].Kid
+	if err = helpers.LoadJWTKeys(cfg); err != nil {
+		t.Fatalf("LoadJWTKeys err: %v", err)
+	}
+	newToken, _ := helpers.GenerateToken(user, time.Now().Add(time.Minute))
+	for _, tokenString := range []string{oldToken, newToken} {
+		if _, err = helpers.ParseToken(tokenString); err != nil {
+			t.Errorf("Expected token valid during rotation, got %v", err)
+		}
+	}
+	cfg.Keys = keys[1:]
+	if err = helpers.LoadJWTKeys(cfg); err != nil {
+		t.Fatalf("LoadJWTKeys err: %v", err)
+	}
+	if _, err = helpers.ParseToken(oldToken); err == nil {
+		t.Errorf("Expected token signed by removed key rejected")
+	}
+}
+
+func TestRefreshSessionConcurrent(t *testing.T) {
+	db := setupTestDB()
+	if err := system.LoadConfiguration("../conf/conf.toml"); err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	db.Exec("DELETE FROM refresh_tokens")
+	db.Exec("DELETE FROM users WHERE username = ?", "session-user")
+	user := &models.User{Username: "session-user", Password: "password", Email: "session@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	raw, _, _ := models.IssueRefreshToken(user.ID, "test", "127.0.0.1")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("blog-session", cookie.NewStore([]byte("test-secret"))))
+	router.GET("/signin", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set(controllers.SessionRefreshKey, raw)
+		session.Save()
+	})
+	router.GET("/refresh", func(c *gin.Context) {
+		if _, err := controllers.RefreshSession(c); err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+		c.String(http.StatusOK, sessions.Default(c).Get(controllers.SessionRefreshKey).(string))
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/signin", nil))
+	cookies := w.Result().Cookies()
+
+	// 同一 session 的并发请求共享轮换结果，不视为重复使用
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		rotated = map[string]int{}
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/refresh", nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			mu.Lock()
+			rotated[fmt.Sprintf("%d %s", w.Code, w.Body.String())]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(rotated) != 1 {
+		t.Fatalf("Expected all requests to share one rotation, got %v", rotated)
+	}
+	for result := range rotated {
+		newRaw := strings.TrimPrefix(result, "200 ")
+		if newRaw == result {
+			t.Fatalf("Expected refresh succeeded, got %s", result)
+		}
+		if _, _, err := models.RotateRefreshToken(newRaw, "test", "127.0.0.1"); err != nil {
+			t.Errorf("Expected replacement token valid, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go-blog/controllers"
+	"go-blog/models"
+	"go-blog/system"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/webp"
+)
+
+// 生成指定尺寸的测试图片
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	return img
+}
+
+var pngData = func() []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(4, 4))
+	return buf.Bytes()
+}()
+
+// 带 EXIF 的 JPEG：方向为 6（顺时针旋转 90 度），并包含 GPS 标记
+func exifJPEG(w, h int) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, testImage(w, h), nil)
+	tiff := "MM\x00\x2a\x00\x00\x00\x08" + "\x00\x01" + "\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + "\x00\x00\x00\x00" + "GPSLatitude"
+	app1 := "Exif\x00\x00" + tiff
+	segment := []byte{0xFF, 0xE1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), append(segment, app1...)...), data[2:]...)
+}
+
+// 模拟 MinIO 的对象存储，只支持 HEAD 与 PUT
+func s3Stub(t *testing.T) (*httptest.Server, map[string][]byte) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodHead:
+			if _, ok := objects[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") != fmt.Sprintf("%x", sha256.Sum256(body)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, objects
+}
+
+func TestS3Uploader(t *testing.T) {
+	server, objects := s3Stub(t)
+	uploader, err := controllers.NewS3Uploader(system.S3{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "blog",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+		PathStyle: true,
+		PublicURL: "https://cdn.example.org/",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Uploader err: %v", err)
+	}
+	upload := system.Upload{MaxSize: 1 << 20, FileTypes: []string{"image/*"}}
+	stored, err := controllers.StoreFile(uploader, bytes.NewReader(pngData), upload)
+	if err != nil {
+		t.Fatalf("StoreFile err: %v", err)
+	}
+	name := fmt.Sprintf("%x.png", sha256.Sum256(pngData))
+	if stored.URL != "https://cdn.example.org/"+name {
+		t.Errorf("Unexpected url: %s", stored.URL)
+	}
+	if !bytes.Equal(objects["/blog/"+name], pngData) {
+		t.Errorf("Object not stored: %v", objects)
+	}
+	// 重复上传返回同一地址，不产生新对象
+	if again, _ := controllers.StoreFile(uploader, bytes.NewReader(pngData), upload); again.URL != stored.URL || len(objects) != 1 {
+		t.Errorf("Expected dedup, got %s and %d objects", again.URL, len(objects))
+	}
+	if _, err = controllers.StoreFile(uploader, strings.NewReader("plain text"), upload); err == nil {
+		t.Error("Expected text file rejected")
+	}
+	if _, err = controllers.StoreFile(uploader, bytes.NewReader(pngData), system.Upload{MaxSize: 8}); err != controllers.ErrFileTooLarge {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+}
+
+func TestUploadImage(t *testing.T) {
+	uploader := controllers.NewMemoryUploader("/upload/")
+	upload := system.Upload{
+		FileTypes:     []string{"image/*"},
+		MaxWidth:      2000,
+		MaxHeight:     2000,
+		StripMetadata: true,
+		Variants:      []system.ImageVariant{{Name: "thumbnail", Width: 150}, {Name: "medium", Width: 800}},
+	}
+	stored, err := controllers.StoreFile(uploader, bytes.NewReader(exifJPEG(1000, 500)), upload)
+	if err != nil {
+		t.Fatalf("StoreFile err: %v", err)
+	}
+	// 按方向摆正后宽高互换，只生成窄于原图的版本
+	if stored.Width != 500 || stored.Height != 1000 || len(stored.Variants) != 1 || stored.Variants[0].Width != 150 || stored.Variants[0].Height != 300 {
+		t.Fatalf("Unexpected stored file: %+v", stored)
+	}
+	data, _ := uploader.Get(stored.Key)
+	if bytes.Contains(data, []byte("Exif")) || bytes.Contains(data, []byte("GPSLatitude")) {
+		t.Error("Expected EXIF stripped")
+	}
+	if conf, err := jpeg.DecodeConfig(bytes.NewReader(data)); err != nil || conf.Width != 500 || conf.Height != 1000 {
+		t.Errorf("Unexpected stored image: %+v %v", conf, err)
+	}
+	if _, ok := uploader.Get(stored.Hash + "-thumbnail.jpg"); !ok {
+		t.Error("Expected thumbnail stored")
+	}
+	if stored.Srcset() != fmt.Sprintf("/upload/%s-thumbnail.jpg 150w, /upload/%s 500w", stored.Hash, stored.Key) {
+		t.Errorf("Unexpected srcset: %s", stored.Srcset())
+	}
+
+	// PNG 元数据无损去除
+	var buf bytes.Buffer
+	png.Encode(&buf, testImage(4, 4))
+	text := "tEXtComment\x00secret"
+	chunk := append([]byte{0, 0, 0, byte(len(text) - 4)}, text...)
+	withText := append(append(append([]byte{}, buf.Bytes()[:33]...), append(chunk, 0, 0, 0, 0)...), buf.Bytes()[33:]...)
+	if stored, err = controllers.StoreFile(uploader, bytes.NewReader(withText), upload); err != nil {
+		t.Fatalf("StoreFile err: %v", err)
+	}
+	if data, _ = uploader.Get(stored.Key); !bytes.Equal(data, buf.Bytes()) {
+		t.Error("Expected PNG text chunk stripped")
+	}
+
+	// WebP 同样校验尺寸并去除 EXIF 块
+	lossless, _ := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+	exif := []byte("EXIF\x05\x00\x00\x00GPS42\x00")
+	vp8x := []byte("VP8X\x0a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	body := append(append(append([]byte("WEBP"), vp8x...), lossless[12:]...), exif...)
+	riff := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(riff[4:], uint32(len(body)))
+	if stored, err = controllers.StoreFile(uploader, bytes.NewReader(riff), upload); err != nil {
+		t.Fatalf("StoreFile webp err: %v", err)
+	}
+	if data, _ = uploader.Get(stored.Key); stored.Width != 1 || bytes.Contains(data, []byte("GPS42")) || data[20]&0x08 != 0 {
+		t.Errorf("Expected WebP EXIF stripped: %+v", stored)
+	}
+	if _, err = webp.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("Expected stripped WebP decodable: %v", err)
+	}
+
+	// JFIF 与 SOF0 之后长度为 0 的 APP1 段不能导致越界
+	buf.Reset()
+	jpeg.Encode(&buf, testImage(4, 4), nil)
+	data = buf.Bytes()
+	sof := bytes.Index(data, []byte{0xFF, 0xC0})
+	sof += 2 + int(binary.BigEndian.Uint16(data[sof+2:]))
+	jfif := []byte("\xFF\xE0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	malformed := append(append(append(append([]byte{}, data[:2]...), jfif...), data[2:sof]...), 0xFF, 0xE1, 0x00, 0x00)
+	malformed = append(malformed, data[sof:]...)
+	if _, err = controllers.StoreFile(uploader, bytes.NewReader(malformed), upload); err == nil {
+		t.Error("Expected malformed JPEG segment rejected")
+	}
+
+	// 无法解码的图片类型无法校验，直接拒绝
+	if _, err = controllers.StoreFile(uploader, bytes.NewReader([]byte("\x00\x00\x01\x00\x01\x00\x10\x10")), upload); err == nil {
+		t.Error("Expected undecodable image rejected")
+	}
+
+	upload.MaxWidth = 400
+	if _, err = controllers.StoreFile(uploader, bytes.NewReader(exifJPEG(1000, 500)), upload); err == nil {
+		t.Error("Expected oversized image rejected")
+	}
+	buf.Reset()
+	bmp.Encode(&buf, testImage(1000, 10))
+	if _, err = controllers.StoreFile(uploader, bytes.NewReader(buf.Bytes()), upload); err == nil {
+		t.Error("Expected oversized BMP rejected")
+	}
+}
+
+func TestUploadBackend(t *testing.T) {
+	setupTestDB()
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "conf.toml")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(controllers.ContextUserKey, &models.User{})
+	})
+	router.POST("/admin/upload", controllers.Upload)
+	upload := func(data []byte) map[string]interface{} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("file", "a.png")
+		part.Write(data)
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/admin/upload", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		res := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return res
+	}
+	defer system.LoadConfiguration("../conf/conf.toml")
+	defer controllers.SetUploader(nil)
+
+	backends := []struct {
+		name     string
+		uploader controllers.Uploader
+	}{{"memory", controllers.NewMemoryUploader("/upload/")}, {"local", nil}}
+	for _, backend := range backends {
+		controllers.SetUploader(backend.uploader)
+		data := fmt.Sprintf("[upload]\nlocal_path = '%s'\nlocal_url = '/static/upload/'\n", filepath.ToSlash(filepath.Join(dir, "upload")))
+		if err := os.WriteFile(conf, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := system.LoadConfiguration(conf); err != nil {
+			t.Fatalf("LoadConfiguration err: %v", err)
+		}
+		first, second := upload(pngData), upload(pngData)
+		if first["succeed"] != true || first["url"] != second["url"] || !strings.HasSuffix(first["url"].(string), ".png") {
+			t.Errorf("%s: unexpected responses %v %v", backend.name, first, second)
+		}
+	}
+	if files, _ := os.ReadDir(filepath.Join(dir, "upload")); len(files) != 1 {
+		t.Errorf("Expected 1 local file, got %d", len(files))
+	}
+
+	// 内存存储没有对应的访问路由，只能在测试中替换，不能通过配置启用
+	if err := os.WriteFile(conf, []byte("file_server = 'memory'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := system.LoadConfiguration(conf); err != nil {
+		t.Fatalf("LoadConfiguration err: %v", err)
+	}
+	if res := upload(pngData); res["succeed"] == true {
+		t.Errorf("Expected file_server memory rejected, got %v", res)
+	}
+}
@@ -3,6 +3,7 @@ package tests
 import (
 	"go-blog/models"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -39,3 +40,139 @@ func TestInsertUser(t *testing.T) {
 		t.Errorf("Expected username 'Alice', got '%s'", u.Username)
 	}
 }
+
+func TestListUserByQuery(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM users")
+	for _, name := range []string{"alice", "alina", "bob"} {
+		user := &models.User{Username: name, Password: "-", Email: name + "@example.com"}
+		if err := user.Insert(); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+	}
+
+	query := models.UserQuery{Username: "ali"}
+	users, err := models.ListUser(query, 1, 1)
+	if err != nil || len(users) != 1 || users[0].Username != "alice" {
+		t.Fatalf("Expected alice on first page, got %v (%v)", users, err)
+	}
+	if total, _ := models.CountUser(query); total != 2 {
+		t.Errorf("Expected 2 users matching 'ali', got %d", total)
+	}
+	if total, _ := models.CountUser(models.UserQuery{Email: "bob@example.com"}); total != 1 {
+		t.Errorf("Expected 1 user matching email, got %d", total)
+	}
+}
+
+func TestUserRole(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM users")
+	var users []*models.User
+	for _, name := range []string{"admin", "writer", "reader"} {
+		user := &models.User{Username: name, Password: "-", Email: name + "@example.com"}
+		if err := user.Insert(); err != nil {
+			t.Fatalf("Failed to insert user: %v", err)
+		}
+		users = append(users, user)
+	}
+	admin, writer, reader := users[0], users[1], users[2]
+
+	// 第一个用户为管理员，其余为默认角色
+	if admin.Role != models.RoleAdmin || writer.Role != models.RoleAuthor {
+		t.Fatalf("Unexpected roles: %s, %s", admin.Role, writer.Role)
+	}
+	if err := reader.UpdateRole(models.RoleVisitor); err != nil {
+		t.Fatalf("UpdateRole err: %v", err)
+	}
+	if reader.Can(models.PermAdminAccess) || !reader.Can(models.PermCommentCreate) {
+		t.Error("Expected visitor to only comment")
+	}
+
+	post := &models.Post{UserID: writer.ID}
+	if !writer.CanEditPost(post) || !admin.CanEditPost(post) || reader.CanEditPost(post) {
+		t.Error("Unexpected CanEditPost result")
+	}
+	other := &models.Post{UserID: admin.ID}
+	if writer.CanEditPost(other) {
+		t.Error("Expected author not to edit others' posts")
+	}
+
+	// 不能取消或锁定最后一个管理员
+	if err := admin.UpdateRole(models.RoleEditor); err != models.ErrLastAdmin {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	if err := admin.UpdateLocked(true); err != models.ErrLastAdmin {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	if err := writer.UpdateLocked(true); err != nil {
+		t.Fatalf("UpdateLocked err: %v", err)
+	}
+	if writer.Can(models.PermPostCreate) {
+		t.Error("Expected locked user to have no permission")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	db := setupTestDB()
+
+	db.Exec("DELETE FROM login_failures")
+	db.Exec("DELETE FROM lockout_events")
+
+	const username, ip = "lockout-user", "10.0.0.1"
+	now := time.Now()
+
+	// 默认连续失败 3 次后需要验证码，5 次后锁定 60 秒
+	var (
+		status models.LoginStatus
+		err    error
+	)
+	for i := 0; i < 3; i++ {
+		if status, err = models.RecordLoginFailure(username, ip, now); err != nil {
+			t.Fatalf("RecordLoginFailure err: %v", err)
+		}
+	}
+	if !status.CaptchaRequired || status.LockedUntil != nil {
+		t.Fatalf("Expected captcha required without lockout, got %+v", status)
+	}
+	for i := 0; i < 2; i++ {
+		if status, err = models.RecordLoginFailure(username, ip, now); err != nil {
+			t.Fatalf("RecordLoginFailure err: %v", err)
+		}
+	}
+	if status.LockedUntil == nil || !status.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("Expected locked for 1 minute, got %+v", status)
+	}
+
+	// 再次达到阈值时锁定时长翻倍
+	later := now.Add(2 * time.Minute)
+	if status = models.CheckLogin(username, ip, later); status.LockedUntil != nil {
+		t.Fatalf("Expected lockout expired, got %v", status.LockedUntil)
+	}
+	for i := 0; i < 5; i++ {
+		if status, err = models.RecordLoginFailure(username, ip, later); err != nil {
+			t.Fatalf("RecordLoginFailure err: %v", err)
+		}
+	}
+	if status.LockedUntil == nil || !status.LockedUntil.Equal(later.Add(2*time.Minute)) {
+		t.Fatalf("Expected locked for 2 minutes, got %+v", status)
+	}
+
+	events, _ := models.ListLockoutEvent(1, 10)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 lockout events, got %d", len(events))
+	}
+	if err = events[0].Clear(1); err != nil {
+		t.Fatalf("Clear err: %v", err)
+	}
+	// 只解除用户名的锁定，IP 的失败计数仍然要求验证码
+	if status = models.CheckLogin(username, ip, later); status.LockedUntil != nil || !status.CaptchaRequired {
+		t.Errorf("Expected username lockout cleared, got %+v", status)
+	}
+
+	// 超过最长锁定时长没有失败时重新开始计数
+	if status, err = models.RecordLoginFailure(username, ip, later.Add(25*time.Hour)); err != nil || status.CaptchaRequired {
+		t.Errorf("Expected failure count restarted, got %+v %v", status, err)
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"go-blog/helpers"
+	"go-blog/jobs"
+	"go-blog/models"
+	"testing"
+	"time"
+)
+
+func TestIsBot(t *testing.T) {
+	for ua, bot := range map[string]bool{
+		"": true,
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": true,
+		"curl/8.5.0": true,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36": false,
+	} {
+		if helpers.IsBot(ua) != bot {
+			t.Errorf("IsBot(%q) expected %v", ua, bot)
+		}
+	}
+}
+
+func TestViewCounting(t *testing.T) {
+	setupTestDB()
+	now := time.Now()
+	jobs.FlushViews(now)
+
+	hot := &models.Post{Title: "hot", Content: "hot", UserID: 1}
+	cold := &models.Post{Title: "cold", Content: "cold", UserID: 1}
+	for _, post := range []*models.Post{hot, cold} {
+		if err := post.Insert(); err != nil {
+			t.Fatalf("Failed to insert post: %v", err)
+		}
+		defer models.DB.Unscoped().Delete(post)
+		defer models.DB.Where("post_id = ?", post.ID).Delete(&models.PostView{})
+	}
+
+	// 同一访客在去重时间内只计一次
+	if !jobs.RecordView(hot.ID, "visitor-a", now) || jobs.RecordView(hot.ID, "visitor-a", now.Add(time.Minute)) {
+		t.Errorf("Expected repeated view deduplicated")
+	}
+	jobs.RecordView(hot.ID, "visitor-b", now)
+	jobs.RecordView(cold.ID, "visitor-a", now)
+	if pending := jobs.PendingViews(hot.ID); pending != 2 {
+		t.Errorf("Expected 2 pending views, got %d", pending)
+	}
+
+	if count, err := jobs.FlushViews(now); err != nil || count != 3 {
+		t.Fatalf("FlushViews = %d, %v", count, err)
+	}
+	if jobs.PendingViews(hot.ID) != 0 {
+		t.Errorf("Expected pending views cleared after flush")
+	}
+	saved, _ := models.GetAnyPostById(hot.ID)
+	if saved.View != 2 {
+		t.Errorf("Expected 2 views persisted, got %d", saved.View)
+	}
+
+	// 超过去重时间后再次计数
+	if !jobs.RecordView(hot.ID, "visitor-a", now.Add(time.Hour)) {
+		t.Errorf("Expected view counted after window")
+	}
+	jobs.FlushViews(now)
+
+	posts, err := models.ListMostReadPost(1, 100)
+	if err != nil {
+		t.Fatalf("ListMostReadPost err: %v", err)
+	}
+	ranks := map[uint]int{}
+	for i, post := range posts {
+		ranks[post.ID] = i + 1
+		if post.ID == hot.ID && post.ViewTotal != 3 {
+			t.Errorf("Expected 3 views in window, got %d", post.ViewTotal)
+		}
+	}
+	if ranks[hot.ID] == 0 || ranks[cold.ID] == 0 || ranks[hot.ID] > ranks[cold.ID] {
+		t.Errorf("Expected hot post ranked above cold post: %v", ranks)
+	}
+	if posts, err = models.ListTrendingPost(7, 100); err != nil || len(posts) < 2 {
+		t.Errorf("ListTrendingPost = %d, %v", len(posts), err)
+	}
+}